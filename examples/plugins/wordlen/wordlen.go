@@ -0,0 +1,38 @@
+// wordlen is an example job plugin, counts the words of each length
+// build: go build -buildmode=plugin -o plugins/wordlen.so ./examples/plugins/wordlen
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var APIVersion = 1
+
+func Map(key, value string, params map[string]string, emit func(key, value string)) error {
+	words := strings.FieldsFunc(value, func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, word := range words {
+		emit(strconv.Itoa(len([]rune(word))), "1")
+	}
+	return nil
+}
+
+func Reduce(key string, values []string, params map[string]string) (string, error) {
+	sum := 0
+	for _, value := range values {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return "", err
+		}
+		sum += count
+	}
+	return strconv.Itoa(sum), nil
+}
+
+func Combine(key string, values []string, params map[string]string) (string, error) {
+	return Reduce(key, values, params)
+}
+
+// plugins are opened by the workers, main is never run
+func main() {}
@@ -3,7 +3,9 @@ module github.com/noobyscoob/grpc-map-reduce
 go 1.20
 
 require (
+	github.com/klauspost/compress v1.16.7
 	golang.org/x/net v0.7.0
+	google.golang.org/genproto v0.0.0-20230221151758-ace64dc21148
 	google.golang.org/grpc v1.53.0
 	google.golang.org/protobuf v1.28.1
 )
@@ -12,5 +14,4 @@ require (
 	github.com/golang/protobuf v1.5.2 // indirect
 	golang.org/x/sys v0.5.0 // indirect
 	golang.org/x/text v0.7.0 // indirect
-	google.golang.org/genproto v0.0.0-20230221151758-ace64dc21148 // indirect
 )
@@ -4,15 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/noobyscoob/grpc-map-reduce/services"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 // global config variable
@@ -20,6 +24,10 @@ var config = services.Config{}
 
 func main() {
 	loadDefaultConfig()
+	if len(config.Plugins.Dir) > 0 {
+		services.PluginDir = config.Plugins.Dir
+	}
+	services.StorageSettings = config.Storage
 	// function name
 	if os.Args[1] == "client" {
 		startRpcClient()
@@ -75,11 +83,26 @@ func startRpcClient() {
 
 	inputFilesPath := os.Args[2]
 	fn := os.Args[3]
+	params, err := parseJobParams(os.Args[4:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	// function can be a pipeline definition file
+	var pipeline *services.Pipeline
+	if strings.HasSuffix(fn, ".json") {
+		pipeline, err = loadPipeline(fn)
+		if err != nil {
+			log.Fatal("Pipeline ", err)
+		}
+	}
 
 	log.Printf("Number of mappers (can be updated in config.json): %d\n", config.Client.NMappers)
 	log.Printf("Number of reducers (can be updated in config.json): %d\n", config.Client.NReducers)
 	log.Printf("Input files are located at: %s\n", inputFilesPath)
-	log.Printf("Running function (wc/ii): %s\n", fn)
+	log.Printf("Running function (wc/ii/iipos/grep/sort/topk/ngram/cooccur/tfidf/pagerank/join/mapjoin/streaming, a plugin or pipeline.json): %s\n", fn)
+	if len(params) > 0 {
+		log.Printf("Job parameters: %v\n", params)
+	}
 
 	log.Printf("Initializing cluster...\n")
 
@@ -91,11 +114,6 @@ func startRpcClient() {
 		log.Fatal(err)
 	}
 
-	inputFiles, err := os.ReadDir(inputFilesPath)
-	if err != nil {
-		log.Fatal("Read Dir", err)
-	}
-
 	log.Printf("Check log files in ./master, ./mappers and ./reducers folders\n")
 	log.Printf("Running map reduce...\n")
 
@@ -104,17 +122,27 @@ func startRpcClient() {
 		log.Fatal("Stream creation error", err)
 	}
 
-	for _, file := range inputFiles {
-		log.Printf(file.Name())
-		bytes, err := os.ReadFile(inputFilesPath + file.Name())
-		if err != nil {
-			log.Fatal("Read file err ", err)
+	// input files are read from storage by the mappers, the master
+	// resolves the paths and globs. Local paths are made absolute
+	// since the master resolves them
+	inputs := []string{}
+	for _, input := range strings.Split(inputFilesPath, ",") {
+		if len(input) == 0 {
+			continue
 		}
-		payload := &services.RunMapRdInput{Fn: fn, File: &services.FileInput{Name: file.Name(), Data: bytes}}
-		err = stream.Send(payload)
-		if err != nil {
-			log.Fatal("Stream Send ", err)
+		if !strings.Contains(input, "://") {
+			input, err = filepath.Abs(input)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
+		log.Printf("Input: %s\n", input)
+		inputs = append(inputs, input)
+	}
+	err = stream.Send(&services.RunMapRdInput{Fn: fn, Params: params, Pipeline: pipeline, Inputs: inputs})
+	if err != nil && err != io.EOF {
+		// on EOF the master closed the stream, the error is returned on receive
+		log.Fatal("Stream Send ", err)
 	}
 
 	// when this is done all the map reduce jobs are done
@@ -162,6 +190,35 @@ func startRpcServer(port string) {
 	grpcServer.Serve(listener)
 }
 
+// job parameters are given as key=value after the function name
+// ex: ./main client ./input/small/ grep pattern=love
+func parseJobParams(args []string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return nil, fmt.Errorf("job parameters must be key=value: %s", arg)
+		}
+		params[kv[0]] = kv[1]
+	}
+	return params, nil
+}
+
+// pipelines are defined in json
+// ex: ./main client ./input/small/ ./pipelines/wc-histogram.json
+func loadPipeline(path string) (*services.Pipeline, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := &services.Pipeline{}
+	err = protojson.Unmarshal(bytes, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
 func loadDefaultConfig() {
 	bytes, _ := os.ReadFile("./config.json")
 	json.Unmarshal(bytes, &config)
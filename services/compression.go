@@ -0,0 +1,281 @@
+package services
+
+import (
+	"compress/gzip"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	// registers the gzip compressor of gRpc
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+// compression of the intermediate data
+// parameters: compression (none/gzip/zstd/snappy, default none)
+// intermediate files (spilled runs, mapper buckets and the files
+// received by the reducers) are written through the codec, and the
+// shuffle and input upload calls use the gRpc compressor of the same name
+
+// counters of the reducers reporting the size of their intermediate
+// files before and after compression
+const (
+	intermediateBytesCounter = "intermediateBytes"
+	compressedBytesCounter   = "intermediateCompressedBytes"
+)
+
+// checksums of the intermediate files are crc32c (castagnoli)
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type codec struct {
+	name      string
+	// extension of the files compressed with the codec
+	ext       string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var codecs = map[string]*codec{
+	"none": {
+		name:      "none",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return nopWriteCloser{w}, nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(r), nil },
+	},
+	"gzip": {
+		name:      "gzip",
+		ext:       ".gz",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+	"zstd": {
+		name: "zstd",
+		ext:  ".zst",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	},
+	// framed snappy format
+	"snappy": {
+		name:      "snappy",
+		ext:       ".sz",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return snappy.NewBufferedWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(snappy.NewReader(r)), nil },
+	},
+}
+
+func init() {
+	// gzip is registered by grpc/encoding/gzip
+	for _, name := range []string{"zstd", "snappy"} {
+		encoding.RegisterCompressor(&grpcCompressor{codec: codecs[name]})
+	}
+}
+
+// codec by name, as given by the compression job parameter
+func lookupCodec(name string) (*codec, error) {
+	if len(name) == 0 {
+		name = "none"
+	}
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q (none/gzip/zstd/snappy)", name)
+	}
+	return c, nil
+}
+
+// call options compressing the messages of a gRpc call with the codec
+func (c *codec) callOptions() []grpc.CallOption {
+	if c == nil || c.name == "none" {
+		return nil
+	}
+	return []grpc.CallOption{grpc.UseCompressor(c.name)}
+}
+
+// gRpc compressor of a codec
+type grpcCompressor struct {
+	codec *codec
+}
+
+func (g *grpcCompressor) Name() string { return g.codec.name }
+
+func (g *grpcCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return g.codec.newWriter(w)
+}
+
+func (g *grpcCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return g.codec.newReader(r)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// counts the bytes going through a writer or a reader
+type byteCounter struct {
+	w io.Writer
+	r io.Reader
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *byteCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// intermediate file of records written through a codec
+type recordFileWriter struct {
+	*recordWriter
+	file       *os.File
+	comp       io.WriteCloser
+	raw        *byteCounter
+	compressed *byteCounter
+	checksum   hash.Hash32
+	closed     bool
+}
+
+func createRecordFile(path string, c *codec) (*recordFileWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	compressed := &byteCounter{w: file}
+	comp, err := c.newWriter(compressed)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// the checksum is computed over the records before compression
+	// so it does not depend on the codec
+	checksum := crc32.New(crc32cTable)
+	raw := &byteCounter{w: io.MultiWriter(comp, checksum)}
+	return &recordFileWriter{
+		recordWriter: newRecordWriter(raw),
+		file:         file,
+		comp:         comp,
+		raw:          raw,
+		compressed:   compressed,
+		checksum:     checksum,
+	}, nil
+}
+
+// flushes the records and closes the file, closing twice is a no-op
+func (w *recordFileWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.Flush()
+	if err == nil {
+		err = w.comp.Close()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// bytes of records written and bytes written to the file
+func (w *recordFileWriter) Stats() (int64, int64) {
+	return w.raw.n, w.compressed.n
+}
+
+// crc32c of the records written
+func (w *recordFileWriter) Checksum() uint32 {
+	return w.checksum.Sum32()
+}
+
+// intermediate file of records read through a codec, errors
+// reading the records are corruptFileErrors
+type recordFileReader struct {
+	*recordReader
+	file     *os.File
+	comp     io.ReadCloser
+	raw      *byteCounter
+	checksum hash.Hash32
+}
+
+func openRecordFile(path string, c *codec) (*recordFileReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		// a missing file is lost like a corrupt one
+		return nil, &corruptFileError{fileName: filepath.Base(path), err: err}
+	}
+	comp, err := c.newReader(file)
+	if err != nil {
+		file.Close()
+		return nil, &corruptFileError{fileName: filepath.Base(path), err: err}
+	}
+	checksum := crc32.New(crc32cTable)
+	raw := &byteCounter{r: io.TeeReader(comp, checksum)}
+	return &recordFileReader{
+		recordReader: newRecordReader(raw),
+		file:         file,
+		comp:         comp,
+		raw:          raw,
+		checksum:     checksum,
+	}, nil
+}
+
+func (r *recordFileReader) Next() (*KeyValue, error) {
+	kv, err := r.recordReader.Next()
+	if err != nil && err != io.EOF {
+		return nil, &corruptFileError{fileName: filepath.Base(r.file.Name()), err: err}
+	}
+	return kv, err
+}
+
+// checks the records read against the manifest of the file,
+// the whole file must have been read
+func (r *recordFileReader) Verify(records int64, checksum uint32) error {
+	name := filepath.Base(r.file.Name())
+	if r.n != records {
+		return &corruptFileError{fileName: name, err: fmt.Errorf("%d records, expected %d", r.n, records)}
+	}
+	if r.checksum.Sum32() != checksum {
+		return &corruptFileError{fileName: name, err: fmt.Errorf("checksum %08x, expected %08x", r.checksum.Sum32(), checksum)}
+	}
+	return nil
+}
+
+func (r *recordFileReader) Close() error {
+	r.comp.Close()
+	return r.file.Close()
+}
+
+// bytes of records read and size of the file
+func (r *recordFileReader) Stats() (int64, int64) {
+	info, err := r.file.Stat()
+	if err != nil {
+		return r.raw.n, 0
+	}
+	return r.raw.n, info.Size()
+}
+
+// percentage of the raw bytes saved by compression
+func savedPercent(raw, compressed int64) float64 {
+	if raw == 0 {
+		return 0
+	}
+	return 100 * float64(raw-compressed) / float64(raw)
+}
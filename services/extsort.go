@@ -0,0 +1,327 @@
+package services
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// external merge sort of the intermediate records
+// mappers sort the map output in runs of at most sortBuffer records
+// spilled to disk and merge the runs into sorted bucket files,
+// reducers merge the sorted bucket files of all the mappers and
+// stream the grouped keys to the reduce function
+
+// records sorted in memory by a mapper, overridden by the
+// sortBuffer job parameter
+const defaultSortBufferRecords = 100000
+
+// runs merged at once, more runs are merged in several passes
+const mergeFactor = 64
+
+// Groups iterates over the keys of a reduce task in key order
+type Groups interface {
+	// advances to the next key, false after the last key or on error
+	Next() bool
+	Key() string
+	// pairs of the current key
+	Values() []*KeyValue
+	Err() error
+}
+
+// sorted stream of records
+type recordSource interface {
+	Next() (*KeyValue, error)
+}
+
+// records of a sorted slice
+type sliceSource struct {
+	kvs []*KeyValue
+}
+
+func (s *sliceSource) Next() (*KeyValue, error) {
+	if len(s.kvs) == 0 {
+		return nil, io.EOF
+	}
+	kv := s.kvs[0]
+	s.kvs = s.kvs[1:]
+	return kv, nil
+}
+
+type mergeItem struct {
+	kv     *KeyValue
+	source int
+}
+
+type mergeHeap struct {
+	items []mergeItem
+	less  func(a, b string) bool
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if a.kv.Key != b.kv.Key {
+		return h.less(a.kv.Key, b.kv.Key)
+	}
+	// keeps the order of the sources for equal keys
+	return a.source < b.source
+}
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *mergeHeap) Push(x any)    { h.items = append(h.items, x.(mergeItem)) }
+func (h *mergeHeap) Pop() any {
+	n := len(h.items)
+	x := h.items[n-1]
+	h.items = h.items[:n-1]
+	return x
+}
+
+// k-way merge of sorted record sources, a recordSource itself
+type mergeIterator struct {
+	sources []recordSource
+	heap    *mergeHeap
+	started bool
+}
+
+func newMergeIterator(sources []recordSource, less func(a, b string) bool) *mergeIterator {
+	return &mergeIterator{sources: sources, heap: &mergeHeap{less: less}}
+}
+
+func (m *mergeIterator) Next() (*KeyValue, error) {
+	if !m.started {
+		m.started = true
+		for i := range m.sources {
+			if err := m.pull(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m.heap.Len() == 0 {
+		return nil, io.EOF
+	}
+	item := heap.Pop(m.heap).(mergeItem)
+	if err := m.pull(item.source); err != nil {
+		return nil, err
+	}
+	return item.kv, nil
+}
+
+// pushes the next record of the source on the heap
+func (m *mergeIterator) pull(source int) error {
+	kv, err := m.sources[source].Next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	heap.Push(m.heap, mergeItem{kv: kv, source: source})
+	return nil
+}
+
+// groups the consecutive records of a sorted source by key
+type groupIterator struct {
+	source recordSource
+	next   *KeyValue
+	key    string
+	values []*KeyValue
+	err    error
+	done   bool
+}
+
+func newGroupIterator(source recordSource) *groupIterator {
+	return &groupIterator{source: source}
+}
+
+func (g *groupIterator) Next() bool {
+	if g.done {
+		return false
+	}
+	if g.next == nil {
+		if !g.read() {
+			return false
+		}
+	}
+	g.key = g.next.Key
+	g.values = []*KeyValue{g.next}
+	for g.read() {
+		if g.next.Key != g.key {
+			return true
+		}
+		g.values = append(g.values, g.next)
+	}
+	// last group, read sets done
+	return g.err == nil
+}
+
+// reads the next record into g.next, false at the end
+func (g *groupIterator) read() bool {
+	kv, err := g.source.Next()
+	if err != nil {
+		if err != io.EOF {
+			g.err = err
+		}
+		g.next = nil
+		g.done = true
+		return false
+	}
+	g.next = kv
+	return true
+}
+
+func (g *groupIterator) Key() string         { return g.key }
+func (g *groupIterator) Values() []*KeyValue { return g.values }
+func (g *groupIterator) Err() error          { return g.err }
+
+// sorts the map output of a task in bounded memory runs
+type spillSorter struct {
+	job    *Job
+	ctx    *TaskContext
+	prefix string
+	limit  int
+	codec  *codec
+	buf    []*KeyValue
+	runs   []string
+	// run files written so far, names the next run
+	nRuns int
+}
+
+func newSpillSorter(job *Job, ctx *TaskContext, prefix string) (*spillSorter, error) {
+	limit, err := ctx.IntParam("sortBuffer", defaultSortBufferRecords)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("sortBuffer must be positive: %d", limit)
+	}
+	codec, err := lookupCodec(ctx.Param("compression", ""))
+	if err != nil {
+		return nil, err
+	}
+	return &spillSorter{job: job, ctx: ctx, prefix: prefix, limit: limit, codec: codec}, nil
+}
+
+func (s *spillSorter) Add(kvs ...*KeyValue) error {
+	for _, kv := range kvs {
+		s.buf = append(s.buf, kv)
+		if len(s.buf) >= s.limit {
+			if err := s.spill(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sorted and combined records of the buffer
+func (s *spillSorter) sorted() ([]*KeyValue, error) {
+	sort.SliceStable(s.buf, func(i, j int) bool { return s.job.less(s.buf[i].Key, s.buf[j].Key) })
+	out := []*KeyValue{}
+	err := combineGroups(s.job, newGroupIterator(&sliceSource{kvs: s.buf}), s.ctx, func(kv *KeyValue) error {
+		out = append(out, kv)
+		return nil
+	})
+	s.buf = nil
+	return out, err
+}
+
+// writes the buffer to disk as a sorted run
+func (s *spillSorter) spill() error {
+	kvs, err := s.sorted()
+	if err != nil {
+		return err
+	}
+	log.Printf("Spilling %d key value pairs to run %d\n", len(kvs), s.nRuns)
+	return s.writeRun(func(emit func(kv *KeyValue) error) error {
+		for _, kv := range kvs {
+			if err := emit(kv); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writes the records produced by fill to a new run file
+func (s *spillSorter) writeRun(fill func(emit func(kv *KeyValue) error) error) error {
+	runPath := fmt.Sprintf("%s_run_%d.bin", s.prefix, s.nRuns)
+	s.nRuns++
+	writer, err := createRecordFile(runPath, s.codec)
+	if err != nil {
+		return err
+	}
+	if err := fill(writer.Write); err != nil {
+		writer.Close()
+		return err
+	}
+	s.runs = append(s.runs, runPath)
+	return writer.Close()
+}
+
+// merges the run files and the extra sources into emit,
+// the run files are removed once they are merged
+func (s *spillSorter) mergeRuns(runs []string, extra []recordSource, emit func(kv *KeyValue) error) error {
+	sources := []recordSource{}
+	for _, runPath := range runs {
+		reader, err := openRecordFile(runPath, s.codec)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(runPath)
+		defer reader.Close()
+		sources = append(sources, reader)
+	}
+	sources = append(sources, extra...)
+
+	merged := newGroupIterator(newMergeIterator(sources, s.job.less))
+	return combineGroups(s.job, merged, s.ctx, emit)
+}
+
+// emits all the records in key order, merging the spilled runs
+func (s *spillSorter) Finish(emit func(kv *KeyValue) error) error {
+	kvs, err := s.sorted()
+	if err != nil {
+		return err
+	}
+	if len(s.runs) == 0 {
+		for _, kv := range kvs {
+			if err := emit(kv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// intermediate passes merge the oldest runs into a new run
+	// until the remaining runs can be merged at once
+	for len(s.runs) >= mergeFactor {
+		log.Printf("Merging %d of %d sorted runs\n", mergeFactor, len(s.runs))
+		runs := s.runs[:mergeFactor]
+		s.runs = s.runs[mergeFactor:]
+		err := s.writeRun(func(emit func(kv *KeyValue) error) error {
+			return s.mergeRuns(runs, nil, emit)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Merging %d sorted runs\n", len(s.runs)+1)
+	return s.mergeRuns(s.runs, []recordSource{&sliceSource{kvs: kvs}}, emit)
+}
+
+// runs the combiner of the job on every group, the
+// pairs are emitted as they are when there is no combiner
+func combineGroups(job *Job, groups Groups, ctx *TaskContext, emit func(kv *KeyValue) error) error {
+	for groups.Next() {
+		for _, kv := range combine(job, groups.Key(), groups.Values(), ctx) {
+			if err := emit(kv); err != nil {
+				return err
+			}
+		}
+	}
+	return groups.Err()
+}
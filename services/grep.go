@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// distributed grep
+// parameters: pattern (regular expression), ignoreCase (true/false)
+// emits "fileName:lineNumber": line for every matching line
+
+func grepMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	pattern := ctx.Param("pattern", "")
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("grep needs a pattern parameter")
+	}
+	if ctx.Param("ignoreCase", "false") == "true" {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grep pattern: %v", err)
+	}
+
+	kvPairs := &KvPairs{}
+	for i, line := range strings.Split(value, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if re.MatchString(line) {
+			kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: fmt.Sprintf("%s:%d", ctx.FileName, i+1), Value: line})
+		}
+	}
+
+	return kvPairs, nil
+}
+
+// every key is a single line of a file
+func grepReduce(key string, values []string, _ *TaskContext) string {
+	return values[0]
+}
+
+// splits "fileName:lineNumber"
+func splitGrepKey(key string) (string, int) {
+	i := strings.LastIndex(key, ":")
+	if i < 0 {
+		return key, 0
+	}
+	line, _ := strconv.Atoi(key[i+1:])
+	return key[:i], line
+}
+
+// all the matches of a file go to the same reducer
+func grepPartition(key string, nReducers int) int {
+	file, _ := splitGrepKey(key)
+	return hashWordToBucket(file) % nReducers
+}
+
+// matches are sorted by file and then by line number
+func grepLess(a, b string) bool {
+	fileA, lineA := splitGrepKey(a)
+	fileB, lineB := splitGrepKey(b)
+	if fileA != fileB {
+		return fileA < fileB
+	}
+	return lineA < lineB
+}
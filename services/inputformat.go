@@ -0,0 +1,292 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// input formats split an input file into the records given to the
+// map function, chosen with the inputFormat job parameter:
+//   - whole (default): the whole file, keyed by the file name
+//   - lines: a record per line (without the line break)
+//   - csv: a record per row, the value is a json object of the fields
+//     by column name from the header row. parameters: csvSep (default ,)
+//   - jsonl: a record per json line, empty lines are skipped
+//   - fixed: binary records of recordBytes bytes
+// records of the other formats are keyed by their byte offset in the
+// file, the file name is in TaskContext.FileName. Jobs mapping whole
+// files (Job.WholeFile) reject the other formats.
+// Compressed files are decompressed before the input format reads them
+type InputFormat interface {
+	// calls fn with the key and value of every record of the file
+	Records(fileName string, r io.Reader, ctx *TaskContext, fn func(key, value string) error) error
+}
+
+var inputFormats = map[string]InputFormat{
+	"whole": wholeFileFormat{},
+	"lines": linesFormat{},
+	"csv":   csvFormat{},
+	"jsonl": jsonLinesFormat{},
+	"fixed": fixedFormat{},
+}
+
+func lookupInputFormat(name string) (InputFormat, error) {
+	format, ok := inputFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown input format %q (whole/lines/csv/jsonl/fixed)", name)
+	}
+	return format, nil
+}
+
+// runs the map function of the job on every record of the file and
+// passes the output pairs to emit, returns the number of records
+func mapRecords(job *Job, fileName string, r io.Reader, ctx *TaskContext, emit func(kvs ...*KeyValue) error) (int, error) {
+	formatName := ctx.Param("inputFormat", "whole")
+	if job.WholeFile && formatName != "whole" {
+		return 0, fmt.Errorf("the job maps whole files, inputFormat %s is not supported", formatName)
+	}
+	format, err := lookupInputFormat(formatName)
+	if err != nil {
+		return 0, err
+	}
+	reader, err := decompressInput(fileName, r, ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	ctx.FileName = fileName
+	records := 0
+	err = format.Records(fileName, reader, ctx, func(key, value string) error {
+		records++
+		kvPairs, err := job.Map(key, value, ctx)
+		if err != nil {
+			return err
+		}
+		return emit(kvPairs.Data...)
+	})
+	return records, err
+}
+
+// compressed input files (.gz, .zst, .bz2 and .sz or detected by
+// their magic bytes) are decompressed by the mapper reading them.
+// A file is the input of a single map task, so a compressed file is
+// decompressed from start to end by one mapper: large compressed
+// inputs are split by storing them as several files.
+// parameters: inputCompression (auto (default)/none/gzip/zstd/bzip2/snappy)
+
+// bzip2 is only read, it is not a codec of the intermediate data
+var bzip2Codec = &codec{
+	name:      "bzip2",
+	ext:       ".bz2",
+	newReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(bzip2.NewReader(r)), nil },
+}
+
+var inputCodecs = []struct {
+	codec *codec
+	// bytes every compressed file starts with
+	magic func(head []byte) bool
+}{
+	{codecs["gzip"], hasMagic("\x1f\x8b")},
+	{codecs["zstd"], hasMagic("\x28\xb5\x2f\xfd")},
+	// "BZh", the block size and the magic of the first block
+	{bzip2Codec, func(head []byte) bool {
+		return len(head) >= 10 && bytes.HasPrefix(head, []byte("BZh")) &&
+			'1' <= head[3] && head[3] <= '9' && string(head[4:10]) == "1AY&SY"
+	}},
+	// stream identifier of the framed format
+	{codecs["snappy"], hasMagic("\xff\x06\x00\x00sNaPpY")},
+}
+
+func hasMagic(magic string) func(head []byte) bool {
+	return func(head []byte) bool {
+		return bytes.HasPrefix(head, []byte(magic))
+	}
+}
+
+// reader of the decompressed input file, the file itself when
+// it is not compressed or inputCompression is none
+func decompressInput(fileName string, r io.Reader, ctx *TaskContext) (io.ReadCloser, error) {
+	name := ctx.Param("inputCompression", "auto")
+	if name == "none" {
+		return io.NopCloser(r), nil
+	}
+	buffered := bufio.NewReader(r)
+	var c *codec
+	for _, input := range inputCodecs {
+		if input.codec.name == name {
+			c = input.codec
+		}
+	}
+	if name == "auto" {
+		head, _ := buffered.Peek(16)
+		c = detectInputCodec(fileName, head)
+		if c == nil {
+			return io.NopCloser(buffered), nil
+		}
+	}
+	if c == nil {
+		return nil, fmt.Errorf("unknown input compression %q (auto/none/gzip/zstd/bzip2/snappy)", name)
+	}
+	reader, err := c.newReader(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %v", fileName, c.name, err)
+	}
+	return reader, nil
+}
+
+// codec of a compressed file by its extension, or its magic bytes
+func detectInputCodec(fileName string, head []byte) *codec {
+	for _, input := range inputCodecs {
+		if strings.HasSuffix(fileName, input.codec.ext) {
+			return input.codec
+		}
+	}
+	for _, input := range inputCodecs {
+		if input.magic(head) {
+			return input.codec
+		}
+	}
+	return nil
+}
+
+type wholeFileFormat struct{}
+
+func (wholeFileFormat) Records(fileName string, r io.Reader, ctx *TaskContext, fn func(key, value string) error) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return fn(fileName, string(data))
+}
+
+// calls fn with every line and its byte offset, lines can be of any length
+func readLines(r io.Reader, fn func(offset int64, line string) error) error {
+	reader := bufio.NewReader(r)
+	var offset int64
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) > 0 {
+			if fnErr := fn(offset, strings.TrimRight(line, "\r\n")); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		offset += int64(len(line))
+	}
+}
+
+type linesFormat struct{}
+
+func (linesFormat) Records(fileName string, r io.Reader, ctx *TaskContext, fn func(key, value string) error) error {
+	return readLines(r, func(offset int64, line string) error {
+		return fn(strconv.FormatInt(offset, 10), line)
+	})
+}
+
+type csvFormat struct{}
+
+func (csvFormat) Records(fileName string, r io.Reader, ctx *TaskContext, fn func(key, value string) error) error {
+	reader := csv.NewReader(r)
+	sep := ctx.Param("csvSep", ",")
+	if utf8.RuneCountInString(sep) != 1 {
+		return fmt.Errorf("csvSep must be a single character: %q", sep)
+	}
+	reader.Comma, _ = utf8.DecodeRuneInString(sep)
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", fileName, err)
+	}
+	// field names are encoded once, the fields keep the column order
+	names := make([]string, len(header))
+	for i, name := range header {
+		encoded, _ := json.Marshal(name)
+		names[i] = string(encoded)
+	}
+	for {
+		offset := reader.InputOffset()
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", fileName, err)
+		}
+		var record strings.Builder
+		record.WriteString("{")
+		for i, field := range fields {
+			if i > 0 {
+				record.WriteString(",")
+			}
+			encoded, _ := json.Marshal(field)
+			record.WriteString(names[i] + ":" + string(encoded))
+		}
+		record.WriteString("}")
+		if err := fn(strconv.FormatInt(offset, 10), record.String()); err != nil {
+			return err
+		}
+	}
+}
+
+type jsonLinesFormat struct{}
+
+func (jsonLinesFormat) Records(fileName string, r io.Reader, ctx *TaskContext, fn func(key, value string) error) error {
+	lineNumber := 0
+	return readLines(r, func(offset int64, line string) error {
+		lineNumber++
+		if len(strings.TrimSpace(line)) == 0 {
+			return nil
+		}
+		if !json.Valid([]byte(line)) {
+			return fmt.Errorf("%s: invalid json on line %d", fileName, lineNumber)
+		}
+		return fn(strconv.FormatInt(offset, 10), line)
+	})
+}
+
+type fixedFormat struct{}
+
+func (fixedFormat) Records(fileName string, r io.Reader, ctx *TaskContext, fn func(key, value string) error) error {
+	size, err := ctx.IntParam("recordBytes", 0)
+	if err != nil {
+		return err
+	}
+	if size <= 0 {
+		return fmt.Errorf("fixed input format needs the recordBytes parameter")
+	}
+	reader := bufio.NewReader(r)
+	record := make([]byte, size)
+	var offset int64
+	for {
+		_, err := io.ReadFull(reader, record)
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%s: size is not a multiple of %d bytes", fileName, size)
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(strconv.FormatInt(offset, 10), string(record)); err != nil {
+			return err
+		}
+		offset += int64(size)
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// input paths of a job, resolved by the master against storage:
+//   - files
+//   - directories, read recursively. Files of a sub directory belong
+//     to the dataset named after it
+//   - globs, path.Match syntax in every path segment and ** for any
+//     number of segments, ex: s3://logs/2024-*/**/*.json. Datasets are
+//     named after the sub directories of the part without wildcards
+// parameters: include, exclude (comma separated globs matched against
+// the path of a file relative to its directory, or against the file
+// name for globs without a slash)
+
+const globMeta = "*?[\\"
+
+// resolves the input paths to the input files, in order and
+// without duplicates. An input matching no file is an error
+func resolveInputs(inputs []string, params map[string]string) ([]*stageInput, error) {
+	include, exclude := splitGlobs(params["include"]), splitGlobs(params["exclude"])
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if err := checkGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	inputFiles := []*stageInput{}
+	seen := map[string]bool{}
+	for _, input := range inputs {
+		dir, names, err := resolveInput(input)
+		if err != nil {
+			return nil, err
+		}
+		matched := 0
+		for _, name := range names {
+			if len(include) > 0 && !matchAny(include, name) || matchAny(exclude, name) {
+				continue
+			}
+			matched++
+			uri := joinURI(dir, name)
+			if seen[uri] {
+				continue
+			}
+			seen[uri] = true
+			dataset, fileName, ok := strings.Cut(name, "/")
+			if !ok {
+				dataset, fileName = "", name
+			}
+			inputFiles = append(inputFiles, &stageInput{name: fileName, uri: uri, dataset: dataset})
+		}
+		if matched == 0 {
+			return nil, fmt.Errorf("no input files match %s", input)
+		}
+	}
+	return inputFiles, nil
+}
+
+// directory of the input and the names of its files relative to it
+func resolveInput(input string) (string, []string, error) {
+	segments := strings.Split(input, "/")
+	for i, segment := range segments {
+		if !strings.ContainsAny(segment, globMeta) {
+			continue
+		}
+		// files under the part without wildcards matching the rest
+		dir, pattern := strings.Join(segments[:i], "/"), strings.Join(segments[i:], "/")
+		if err := checkGlob(pattern); err != nil {
+			return "", nil, err
+		}
+		if len(dir) == 0 {
+			dir = "."
+		}
+		names, err := listURI(dir)
+		if err != nil {
+			return "", nil, err
+		}
+		matches := []string{}
+		for _, name := range names {
+			if matchGlob(pattern, name) {
+				matches = append(matches, name)
+			}
+		}
+		return dir, matches, nil
+	}
+
+	names, err := listURI(input)
+	if err != nil {
+		return "", nil, err
+	}
+	// an empty directory matches no file
+	if len(names) > 0 || strings.HasSuffix(input, "/") {
+		return input, names, nil
+	}
+	// not a directory, a single file
+	reader, err := openURI(input)
+	if errors.Is(err, errDirectory) {
+		return input, names, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	reader.Close()
+	dir, name := ".", input
+	if i := strings.LastIndex(input, "/"); i >= 0 {
+		dir, name = input[:i], input[i+1:]
+	}
+	return dir, []string{name}, nil
+}
+
+func splitGlobs(list string) []string {
+	globs := []string{}
+	for _, glob := range strings.Split(list, ",") {
+		if glob = strings.TrimSpace(glob); len(glob) > 0 {
+			globs = append(globs, glob)
+		}
+	}
+	return globs
+}
+
+func checkGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("bad glob %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// include and exclude globs without a slash match the file name
+func matchAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if !strings.Contains(glob, "/") && matchGlob(glob, path.Base(name)) || matchGlob(glob, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		// any number of segments, zero included
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// integrity of the intermediate data
+// every partition file has the crc32c checksum and the record count
+// of its records in the map manifest. Mappers verify their files when
+// sending them and reducers verify the files they received before the
+// output is sent, a corrupt file fails the call with a DataLoss status
+// naming the file so the master runs the producing map task again.
+// Output files carry the crc32c of their data on the last chunk
+
+// map tasks run again for corrupt partitions before the stage fails
+const maxCorruptRetries = 3
+
+// intermediate file that cannot be read or does not match its manifest
+type corruptFileError struct {
+	fileName string
+	err      error
+}
+
+func (e *corruptFileError) Error() string {
+	return fmt.Sprintf("corrupt intermediate file %s: %v", e.fileName, e.err)
+}
+
+// DataLoss status of an rpc failing on corrupt files, the file
+// names are sent as details. Other errors are returned as they are
+func corruptStatus(err error, fileNames ...string) error {
+	var corrupt *corruptFileError
+	if errors.As(err, &corrupt) {
+		fileNames = append(fileNames, corrupt.fileName)
+	}
+	if len(fileNames) == 0 {
+		return err
+	}
+	st := status.New(codes.DataLoss, err.Error())
+	for _, fileName := range fileNames {
+		withDetails, detailsErr := st.WithDetails(&errdetails.ResourceInfo{ResourceType: "partition", ResourceName: fileName})
+		if detailsErr == nil {
+			st = withDetails
+		}
+	}
+	return st.Err()
+}
+
+// names of the corrupt files of an rpc error
+func corruptFiles(err error) []string {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.DataLoss {
+		return nil
+	}
+	fileNames := []string{}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ResourceInfo); ok && info.ResourceType == "partition" {
+			fileNames = append(fileNames, info.ResourceName)
+		}
+	}
+	return fileNames
+}
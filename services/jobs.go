@@ -0,0 +1,200 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MapFn takes an input record (file name and file contents by
+// default, see InputFormat) and emits intermediate key value pairs
+type MapFn func(key, value string, ctx *TaskContext) (*KvPairs, error)
+
+// ReduceFn reduces all the values grouped under a key
+// to the output value of that key
+type ReduceFn func(key string, values []string, ctx *TaskContext) string
+
+// ReduceValuesFn is a ReduceFn over typed values
+type ReduceValuesFn func(key string, values []*Value, ctx *TaskContext) (*Value, error)
+
+// ReduceAllFn reduces the grouped keys of a reducer as they are
+// merged from the intermediate files, output pairs are written with emit
+type ReduceAllFn func(groups Groups, ctx *TaskContext, emit func(kv *KeyValue) error) error
+
+// Job is a map and reduce function pair that can be run by the
+// cluster, jobs are looked up by the function name sent by the client
+type Job struct {
+	Map    MapFn
+	Reduce ReduceFn
+	// runs on the mapper over the values of each key before they are
+	// sent to the reducers, optional. The output is reduced again so
+	// it must be a partial reduce function (ex: sum)
+	Combine ReduceFn
+	// typed versions of Reduce and Combine, used instead of them
+	// when set. String values are passed as string typed values
+	ReduceValues  ReduceValuesFn
+	CombineValues ReduceValuesFn
+	// orders the keys in the reducer output, lexicographic when nil
+	Less func(a, b string) bool
+	// the map function needs whole input files (line numbers,
+	// header rows...), input formats other than whole are rejected
+	WholeFile bool
+	// assigns a key to one of the reducers, hashes the key when nil
+	Partition func(key string, nReducers int) int
+	// master samples the map output and assigns key ranges to
+	// reducers, so reducer outputs in port order are totally ordered
+	RangePartition bool
+	// reduces all the keys of the reducer at once (keys in order)
+	// instead of calling Reduce per key, lets a key produce
+	// many output lines or keep state across keys, optional
+	ReduceAll ReduceAllFn
+	// runs on the master before the map tasks of a stage, returns the
+	// job parameters completed from the input files, optional
+	Prepare func(inputFiles []*stageInput, params map[string]string) (map[string]string, error)
+	// runs on the master to combine all reducer outputs
+	// (read in memory) into a single output file, optional
+	Merge func(outputs []*FileOutput, ctx *TaskContext) (*FileOutput, error)
+}
+
+// function registry
+var jobs = map[string]*Job{
+	"wc":      {Map: wcMap, ReduceValues: wcReduce, CombineValues: wcReduce},
+	"ii":      {Map: invIndexMap, Reduce: invIndexReduce},
+	"iipos":   {Map: invIndexPosMap, Reduce: invIndexPosReduce, WholeFile: true},
+	"grep":    {Map: grepMap, Reduce: grepReduce, Less: grepLess, Partition: grepPartition, WholeFile: true},
+	"sort":    {Map: sortMap, Reduce: sortReduce, RangePartition: true},
+	"topk":    {Map: wcMap, ReduceValues: wcReduce, CombineValues: wcReduce, ReduceAll: topkReduceAll, Merge: topkMerge},
+	"ngram":   {Map: ngramMap, ReduceValues: wcReduce, CombineValues: wcReduce},
+	"cooccur": {Map: cooccurMap, ReduceValues: wcReduce, CombineValues: wcReduce},
+	// stages of tfidf
+	"tfidf_tf":  {Map: tfMap, ReduceValues: wcReduce, CombineValues: wcReduce},
+	"tfidf_idf": {Map: idfMap, Reduce: idfReduce},
+	// stages of pagerank
+	"pagerank_init": {Map: pagerankInitMap, Reduce: pagerankInitReduce},
+	"pagerank_iter": {Map: pagerankIterMap, Reduce: pagerankIterReduce},
+	"join":          {Map: joinMap, ReduceAll: joinReduceAll, Prepare: joinPrepare, WholeFile: true},
+	"mapjoin":       {Map: mapJoinMap, ReduceAll: identityReduceAll, WholeFile: true},
+	"streaming":     {Map: streamingMap, ReduceAll: streamingReduceAll, WholeFile: true},
+	"script":        {Map: scriptMap, ReduceAll: scriptReduceAll, WholeFile: true},
+}
+
+// registered job or a job loaded from a plugin
+func lookupJob(fn string) (*Job, error) {
+	job, ok := jobs[fn]
+	if !ok {
+		return lookupPluginJob(fn)
+	}
+	return job, nil
+}
+
+// key order of the job
+func (j *Job) less(a, b string) bool {
+	if j.Less != nil {
+		return j.Less(a, b)
+	}
+	return a < b
+}
+
+// bucket of the key among nReducers, splits are the range
+// boundaries sampled by the master for range partitioned jobs
+func (j *Job) partition(key string, nReducers int, splits []string) int {
+	if len(splits) > 0 {
+		return sort.Search(len(splits), func(i int) bool { return j.less(key, splits[i]) })
+	}
+	if j.Partition != nil {
+		return j.Partition(key, nReducers)
+	}
+	return hashWordToBucket(key) % nReducers
+}
+
+// text values of the current key
+func groupValues(groups Groups) []string {
+	values := make([]string, 0, len(groups.Values()))
+	for _, kv := range groups.Values() {
+		values = append(values, textOf(kv))
+	}
+	return values
+}
+
+// parses the "key: value" lines written by the reducers, see parseOutputLine
+func parseOutputLines(data string) []*KeyValue {
+	kvs := []*KeyValue{}
+	for _, line := range strings.Split(data, "\n") {
+		if kv, ok := parseOutputLine(line); ok {
+			kvs = append(kvs, kv)
+		}
+	}
+	return kvs
+}
+
+// lines are split at the first ": ", values may contain it (grep
+// lines, join records...) but keys read back from the outputs of a
+// stage, by the next stage or the master, must not
+func parseOutputLine(line string) (*KeyValue, bool) {
+	i := strings.Index(line, ": ")
+	if i < 0 {
+		return nil, false
+	}
+	return &KeyValue{Key: line[:i], Value: line[i+2:]}, true
+}
+
+// TaskContext carries the job parameters given by the client
+// to the map and reduce functions, reduce functions can also
+// increment counters that are reported back to the master
+type TaskContext struct {
+	Params   map[string]string
+	Counters map[string]int64
+	// input file and dataset of a map task
+	FileName string
+	Dataset  string
+	// files of the broadcast dataset of a map task
+	SideInputs []*FileInput
+}
+
+func newTaskContext(params map[string]string) *TaskContext {
+	if params == nil {
+		params = map[string]string{}
+	}
+	return &TaskContext{Params: params, Counters: map[string]int64{}}
+}
+
+// Incr adds delta to the counter
+func (c *TaskContext) Incr(name string, delta int64) {
+	c.Counters[name] += delta
+}
+
+// Param returns the job parameter or def when it is not set
+func (c *TaskContext) Param(name, def string) string {
+	value, ok := c.Params[name]
+	if !ok || len(value) == 0 {
+		return def
+	}
+	return value
+}
+
+// FloatParam returns the job parameter as a float
+func (c *TaskContext) FloatParam(name string, def float64) (float64, error) {
+	value, ok := c.Params[name]
+	if !ok || len(value) == 0 {
+		return def, nil
+	}
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return def, fmt.Errorf("parameter %s must be a number: %s", name, value)
+	}
+	return floatVal, nil
+}
+
+// IntParam returns the job parameter as an integer
+func (c *TaskContext) IntParam(name string, def int) (int, error) {
+	value, ok := c.Params[name]
+	if !ok || len(value) == 0 {
+		return def, nil
+	}
+	intVal, err := strconv.Atoi(value)
+	if err != nil {
+		return def, fmt.Errorf("parameter %s must be an integer: %s", name, value)
+	}
+	return intVal, nil
+}
@@ -0,0 +1,23 @@
+package services
+
+import "testing"
+
+func TestParseOutputLine(t *testing.T) {
+	tests := []struct {
+		line, key, value string
+	}{
+		{"word: 3", "word", "3"},
+		{"letter.txt:2: Gregory: read it", "letter.txt:2", "Gregory: read it"},
+		{"1: 100,1,note: fragile,12", "1", "100,1,note: fragile,12"},
+		{"empty: ", "empty", ""},
+	}
+	for _, test := range tests {
+		kv, ok := parseOutputLine(test.line)
+		if !ok || kv.Key != test.key || kv.Value != test.value {
+			t.Errorf("%q parsed as %v, want key %q and value %q", test.line, kv, test.key, test.value)
+		}
+	}
+	if kv, ok := parseOutputLine("no separator"); ok {
+		t.Errorf("line without separator parsed as %v", kv)
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// joins two datasets of delimited records on a key column
+// input datasets are the sub directories of the client input path
+// parameters:
+//   left, right: names of the datasets
+//   type: inner (default), left, right or outer
+//   sep: field separator (default ",")
+//   leftKey, rightKey: key column of each dataset (default 0)
+//   header: true to skip the first line of every file
+//   leftFields, rightFields: number of fields of the records of each
+//   dataset, counted by the master in the first line of the dataset
+// emits "key: leftRecord<sep>rightRecord", the missing side of
+// left/right/outer joins is a record of empty fields, so every
+// output line has leftFields + rightFields fields
+//
+// join is a reduce side join, records are tagged with their
+// dataset by the map function and joined per key by the reducer,
+// records of self joins (left == right) are tagged with both sides.
+// mapjoin is a map side join, the right dataset is given as the
+// broadcast parameter and shipped to every mapper, so only the
+// left dataset is mapped and only inner and left joins are supported
+
+const (
+	leftTag  = "L"
+	rightTag = "R"
+)
+
+type joinSpec struct {
+	left, right       string
+	joinType          string
+	sep               string
+	leftKey, rightKey int
+	header            bool
+	// fields of the records of each dataset, 1 when unknown
+	leftFields, rightFields int
+}
+
+func parseJoinSpec(ctx *TaskContext) (*joinSpec, error) {
+	spec := &joinSpec{
+		left:     ctx.Param("left", ""),
+		right:    ctx.Param("right", ctx.Param("broadcast", "")),
+		joinType: ctx.Param("type", "inner"),
+		sep:      ctx.Param("sep", ","),
+		header:   ctx.Param("header", "false") == "true",
+	}
+	if len(spec.left) == 0 || len(spec.right) == 0 {
+		return nil, fmt.Errorf("join needs the left and right datasets")
+	}
+	switch spec.joinType {
+	case "inner", "left", "right", "outer":
+	default:
+		return nil, fmt.Errorf("unknown join type: %s", spec.joinType)
+	}
+	var err error
+	spec.leftKey, err = ctx.IntParam("leftKey", 0)
+	if err != nil {
+		return nil, err
+	}
+	spec.rightKey, err = ctx.IntParam("rightKey", 0)
+	if err != nil {
+		return nil, err
+	}
+	spec.leftFields, err = ctx.IntParam("leftFields", 1)
+	if err != nil {
+		return nil, err
+	}
+	spec.rightFields, err = ctx.IntParam("rightFields", 1)
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// record of empty fields standing for the missing side of a join
+func (spec *joinSpec) emptyRecord(fields int) string {
+	if fields < 1 {
+		fields = 1
+	}
+	return strings.Repeat(spec.sep, fields-1)
+}
+
+// number of fields of the first line of the data
+func countFields(data, sep string) int {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) > 0 {
+			return len(strings.Split(line, sep))
+		}
+	}
+	return 0
+}
+
+// counts the fields of the first line of each dataset, the reducers
+// pad the missing side of left, right and outer joins with them
+func joinPrepare(inputFiles []*stageInput, params map[string]string) (map[string]string, error) {
+	spec, err := parseJoinSpec(newTaskContext(params))
+	if err != nil {
+		return nil, err
+	}
+	prepared := map[string]string{}
+	for k, v := range params {
+		prepared[k] = v
+	}
+	for _, side := range []struct{ dataset, param string }{{spec.left, "leftFields"}, {spec.right, "rightFields"}} {
+		if _, ok := params[side.param]; ok {
+			continue
+		}
+		for _, file := range inputFiles {
+			if file.dataset != side.dataset {
+				continue
+			}
+			fields, err := firstLineFields(file, spec.sep, params)
+			if err != nil {
+				return nil, err
+			}
+			if fields > 0 {
+				prepared[side.param] = strconv.Itoa(fields)
+				break
+			}
+		}
+	}
+	return prepared, nil
+}
+
+func firstLineFields(file *stageInput, sep string, params map[string]string) (int, error) {
+	reader, err := file.open()
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	decompressed, err := decompressInput(file.name, reader, newTaskContext(params))
+	if err != nil {
+		return 0, err
+	}
+	defer decompressed.Close()
+	lines := bufio.NewReader(decompressed)
+	for {
+		line, err := lines.ReadString('\n')
+		if fields := countFields(line, sep); fields > 0 {
+			return fields, nil
+		}
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// calls fn with the key column and the record of every line
+func forEachRecord(fileName, data string, spec *joinSpec, keyColumn int, fn func(key, record string)) error {
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) == 0 || (i == 0 && spec.header) {
+			continue
+		}
+		fields := strings.Split(line, spec.sep)
+		if keyColumn < 0 || keyColumn >= len(fields) {
+			return fmt.Errorf("%s line %d has no key column %d", fileName, i+1, keyColumn)
+		}
+		fn(fields[keyColumn], line)
+	}
+	return nil
+}
+
+// tags every record with the side of the join it comes from,
+// or with both sides in a self join
+func joinMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	spec, err := parseJoinSpec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Dataset != spec.left && ctx.Dataset != spec.right {
+		return nil, fmt.Errorf("file %s of dataset %q is not part of the join", ctx.FileName, ctx.Dataset)
+	}
+
+	kvPairs := &KvPairs{}
+	sides := []struct {
+		dataset, tag string
+		keyColumn    int
+	}{{spec.left, leftTag, spec.leftKey}, {spec.right, rightTag, spec.rightKey}}
+	for _, side := range sides {
+		if ctx.Dataset != side.dataset {
+			continue
+		}
+		err = forEachRecord(ctx.FileName, value, spec, side.keyColumn, func(joinKey, record string) {
+			kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: joinKey, Value: side.tag + "\t" + record})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return kvPairs, nil
+}
+
+func joinReduceAll(groups Groups, ctx *TaskContext, emit func(kv *KeyValue) error) error {
+	spec, err := parseJoinSpec(ctx)
+	if err != nil {
+		return err
+	}
+
+	for groups.Next() {
+		key := groups.Key()
+		lefts, rights := []string{}, []string{}
+		for _, value := range groupValues(groups) {
+			tagRecord := strings.SplitN(value, "\t", 2)
+			if len(tagRecord) != 2 {
+				return fmt.Errorf("invalid join record: %s", value)
+			}
+			if tagRecord[0] == leftTag {
+				lefts = append(lefts, tagRecord[1])
+			} else {
+				rights = append(rights, tagRecord[1])
+			}
+		}
+
+		// the missing side of outer joins is a record of empty fields
+		if len(rights) == 0 && (spec.joinType == "left" || spec.joinType == "outer") {
+			rights = append(rights, spec.emptyRecord(spec.rightFields))
+		}
+		if len(lefts) == 0 && (spec.joinType == "right" || spec.joinType == "outer") {
+			lefts = append(lefts, spec.emptyRecord(spec.leftFields))
+		}
+		for _, left := range lefts {
+			for _, right := range rights {
+				if err := emit(&KeyValue{Key: key, Value: left + spec.sep + right}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return groups.Err()
+}
+
+// joins the records of the left dataset with the broadcast
+// dataset in a hash table, records are joined by the mapper
+func mapJoinMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	spec, err := parseJoinSpec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if spec.joinType != "inner" && spec.joinType != "left" {
+		return nil, fmt.Errorf("map side join supports inner and left joins only")
+	}
+	if ctx.Dataset != spec.left {
+		return nil, fmt.Errorf("file %s of dataset %q is not part of the join", ctx.FileName, ctx.Dataset)
+	}
+
+	// the broadcast dataset is on every mapper, its fields are counted here
+	rightFields := spec.rightFields
+	if _, ok := ctx.Params["rightFields"]; !ok && len(ctx.SideInputs) > 0 {
+		rightFields = countFields(string(ctx.SideInputs[0].Data), spec.sep)
+	}
+	table := map[string][]string{}
+	for _, file := range ctx.SideInputs {
+		err = forEachRecord(file.Name, string(file.Data), spec, spec.rightKey, func(joinKey, record string) {
+			table[joinKey] = append(table[joinKey], record)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	kvPairs := &KvPairs{}
+	err = forEachRecord(ctx.FileName, value, spec, spec.leftKey, func(joinKey, record string) {
+		rights, ok := table[joinKey]
+		if !ok && spec.joinType == "left" {
+			rights = []string{spec.emptyRecord(rightFields)}
+		}
+		for _, right := range rights {
+			kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: joinKey, Value: record + spec.sep + right})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kvPairs, nil
+}
+
+// every value is an output line of its key
+func identityReduceAll(groups Groups, _ *TaskContext, emit func(kv *KeyValue) error) error {
+	for groups.Next() {
+		for _, value := range groupValues(groups) {
+			if err := emit(&KeyValue{Key: groups.Key(), Value: value}); err != nil {
+				return err
+			}
+		}
+	}
+	return groups.Err()
+}
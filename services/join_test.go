@@ -0,0 +1,30 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+// records of a self join are tagged with both sides, on their own key column
+func TestJoinMapSelfJoin(t *testing.T) {
+	ctx := newTaskContext(map[string]string{"left": "orders", "right": "orders", "leftKey": "0", "rightKey": "1"})
+	ctx.FileName = "orders.csv"
+	ctx.Dataset = "orders"
+	kvPairs, err := joinMap("orders.csv", "100,1,book\n101,2,pen\n", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, kv := range kvPairs.Data {
+		got = append(got, kv.Key+" "+kv.Value)
+	}
+	want := []string{"100 L\t100,1,book", "101 L\t101,2,pen", "1 R\t100,1,book", "2 R\t101,2,pen"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("mapped %q, want %q", got, want)
+	}
+
+	ctx.Dataset = "users"
+	if _, err := joinMap("users.csv", "1,alice\n", ctx); err == nil {
+		t.Errorf("dataset users mapped in a join of orders with orders")
+	}
+}
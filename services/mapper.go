@@ -1,15 +1,15 @@
 package services
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
 	"log"
 	"os"
-	"sort"
-	"strconv"
+	"path/filepath"
 	"strings"
-	"time"
 	"unicode"
 
 	"golang.org/x/net/context"
@@ -19,86 +19,245 @@ import (
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
-// KvSorter sorter sorts key value pairs based on the key
-type KvSorter []*KeyValue
-
-func (k KvSorter) Len() int { return len(k) }
-func (k KvSorter) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
-func (k KvSorter) Less(i, j int) bool { return k[i].Key < k[j].Key }
-
 type MapperServer struct {
 	UnimplementedMapperServiceServer
 }
 
 var mapperRootPath string
+var mapperPort string
 
-func (ms *MapperServer) RunMap(ctx context.Context, input *RunMapInput) (*emptypb.Empty, error) {
+func (ms *MapperServer) RunMap(stream MapperService_RunMapServer) error {
+	input, spoolPath, err := receiveMapInput(stream)
+	if len(spoolPath) > 0 {
+		defer os.Remove(spoolPath)
+	}
+	if err != nil {
+		log.Printf("Error receiving map task: %v\n", err)
+		return err
+	}
+	manifest, err := runMap(input)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(manifest)
+}
+
+// assembles the chunks of a map task into a single input. Chunks of
+// the input file are spooled to a local file, returned with the input,
+// which is read through FileUri like the files in storage
+func receiveMapInput(stream MapperService_RunMapServer) (*RunMapInput, string, error) {
+	var input *RunMapInput
+	var spool *os.File
+	spoolPath := ""
+	defer func() {
+		if spool != nil {
+			spool.Close()
+		}
+	}()
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, spoolPath, err
+		}
+		sideInputs := chunk.SideInputs
+		// task fields are set on the first chunk
+		if input == nil {
+			input = chunk
+			input.SideInputs = nil
+		}
+		if len(chunk.FileData) > 0 {
+			if spool == nil {
+				spool, err = os.CreateTemp(mapperRootPath, "input_*")
+				if err != nil {
+					return nil, spoolPath, err
+				}
+				spoolPath = spool.Name()
+			}
+			if _, err := spool.Write(chunk.FileData); err != nil {
+				return nil, spoolPath, err
+			}
+			chunk.FileData = nil
+		}
+		for _, side := range sideInputs {
+			last := len(input.SideInputs) - 1
+			if last >= 0 && input.SideInputs[last].Name == side.Name && input.SideInputs[last].Dataset == side.Dataset {
+				input.SideInputs[last].Data = append(input.SideInputs[last].Data, side.Data...)
+				continue
+			}
+			input.SideInputs = append(input.SideInputs, side)
+		}
+	}
+	if input == nil {
+		return nil, spoolPath, fmt.Errorf("empty map task")
+	}
+	if spool != nil {
+		if err := spool.Close(); err != nil {
+			return nil, spoolPath, err
+		}
+		spool = nil
+		input.FileUri = spoolPath
+	}
+
+	// side inputs in storage are read from there, the
+	// input file is streamed by runMap
+	taskCtx := newTaskContext(input.Params)
+	for _, side := range input.SideInputs {
+		if len(side.Uri) > 0 {
+			sideData, err := readURI(side.Uri)
+			if err != nil {
+				return nil, spoolPath, err
+			}
+			side.Data = sideData
+		}
+		// compressed side inputs are decompressed like the input file
+		reader, err := decompressInput(side.Name, bytes.NewReader(side.Data), taskCtx)
+		if err != nil {
+			return nil, spoolPath, err
+		}
+		side.Data, err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, spoolPath, fmt.Errorf("%s: %v", side.Name, err)
+		}
+	}
+	return input, spoolPath, nil
+}
+
+func runMap(input *RunMapInput) (*MapManifest, error) {
 	log.Printf("Starting map function on the file: %s\n", input.FileName)
-	var kvPairs *KvPairs
 	// runs map function based on input
 	log.Printf("Function: %s\n", input.Fn)
-	if input.Fn == "wc" {
-		kvPairs = wcMap(input.FileName, string(input.FileData))
-	} else {
-		kvPairs = invIndexMap(input.FileName, string(input.FileData))
+	job, err := lookupJob(input.Fn)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return &MapManifest{}, err
+	}
+	taskCtx := newTaskContext(input.Params)
+	taskCtx.Dataset = input.Dataset
+	taskCtx.SideInputs = input.SideInputs
+
+	// the map output is sorted in the key order of the job, in runs
+	// of bounded size spilled to disk when the output is large
+	prefix := fmt.Sprintf("%s/%s_task_%d", mapperRootPath, input.Fn, input.TaskId)
+	sorter, err := newSpillSorter(job, taskCtx, prefix)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return &MapManifest{}, err
 	}
 
-	log.Printf("Sorting intermediate key value pairs!\n")
-	// sort kvPairs
-	sort.Sort(KvSorter(kvPairs.Data))
+	// files in storage and the input files spooled by receiveMapInput
+	// are streamed to the input format
+	var reader io.Reader = bytes.NewReader(input.FileData)
+	if len(input.FileUri) > 0 {
+		log.Printf("Reading input file: %s\n", input.FileUri)
+		file, err := openURI(input.FileUri)
+		if err != nil {
+			log.Printf("Error: %v\n", err)
+			return &MapManifest{}, err
+		}
+		defer file.Close()
+		reader = file
+	}
+	pairs := 0
+	records, err := mapRecords(job, input.FileName, reader, taskCtx, func(kvs ...*KeyValue) error {
+		pairs += len(kvs)
+		return sorter.Add(kvs...)
+	})
+	if err != nil {
+		log.Printf("Error running map function: %v\n", err)
+		return &MapManifest{}, err
+	}
 
-	log.Printf("Map operation done!\n")
+	log.Printf("Map operation done! %d records\n", records)
+	log.Printf("Sorting %d intermediate key value pairs!\n", pairs)
 
 	nReducers := int(input.NReducers)
 	// hash the pairs according to the reducer
 	// hashing the word gives the bucket
-	var reducerBuckets = map[int]*KvPairs{}
-	for i := 0; i < nReducers; i++ {
-		reducerBuckets[i] = &KvPairs{}
+	// every bucket is an intermediate file of length delimited
+	// records, sorted since the pairs are written in key order
+	log.Printf("Writing intermediate files\n")
+	buckets := []int{}
+	for _, partition := range input.Partitions {
+		buckets = append(buckets, int(partition))
+	}
+	if len(buckets) == 0 {
+		for bucket := 0; bucket < nReducers; bucket++ {
+			buckets = append(buckets, bucket)
+		}
+	} else {
+		log.Printf("Writing partitions %v only\n", buckets)
+	}
+	bucketWriters := make([]*recordFileWriter, nReducers)
+	// the manifest keeps the partition of every bucket file so it
+	// is never parsed from the file name
+	manifest := &MapManifest{TaskId: input.TaskId}
+	for _, bucket := range buckets {
+		if bucket < 0 || bucket >= nReducers {
+			return &MapManifest{}, fmt.Errorf("partition %d out of range", bucket)
+		}
+		bucketPath := fmt.Sprintf("%s_bucket_%d.bin", prefix, bucket)
+		manifest.Partitions = append(manifest.Partitions, &PartitionFile{
+			Partition: int32(bucket),
+			Location: "localhost:" + mapperPort,
+			FileName: filepath.Base(bucketPath),
+		})
+		bucketWriters[bucket], err = createRecordFile(bucketPath, sorter.codec)
+		if err != nil {
+			log.Printf("Error creating intermediate file: %v\n", err)
+			return &MapManifest{}, err
+		}
+		defer bucketWriters[bucket].Close()
 	}
 
 	// bucket each pair
 	log.Printf("Hashing keys into different buckets for reduce task\n")
-	for _, pair := range kvPairs.Data {
-		hsh := hashWordToBucket(pair.Key)
-		bucket := hsh % nReducers
-		reducerBuckets[bucket].Data = append(reducerBuckets[bucket].Data, pair)
+	err = sorter.Finish(func(pair *KeyValue) error {
+		bucket := job.partition(pair.Key, nReducers, input.Splits)
+		if bucketWriters[bucket] == nil {
+			// partition not written by this task
+			return nil
+		}
+		return bucketWriters[bucket].Write(pair)
+	})
+	if err != nil {
+		log.Printf("Error writing serialized data: %v\n", err)
+		return &MapManifest{}, err
 	}
 
-	// write buckets to intermediate files
-	log.Printf("Writing intermediate files\n")
-	for bucket := range reducerBuckets {
-		data, err := proto.Marshal(reducerBuckets[bucket])
-		if err != nil {
-			log.Printf("Error seriazlizing data: %v\n", err)
-			return &emptypb.Empty{}, err
-		}
-		bucketName := fmt.Sprintf("%s_task_%d_bucket_%d.bin", input.Fn, input.TaskId, bucket)
-		bucketPath := fmt.Sprintf("%s/%s", mapperRootPath, bucketName)
-		err = os.WriteFile(bucketPath, data, 0644)
+	var rawBytes, compressedBytes int64
+	for i, bucket := range buckets {
+		writer := bucketWriters[bucket]
+		err = writer.Close()
 		if err != nil {
 			log.Printf("Error writing serialized data: %v\n", err)
-			return &emptypb.Empty{}, err
+			return &MapManifest{}, err
 		}
+		raw, compressed := writer.Stats()
+		rawBytes += raw
+		compressedBytes += compressed
+		partition := manifest.Partitions[i]
+		partition.Records = writer.n
+		partition.Bytes = compressed
+		partition.Checksum = writer.Checksum()
 	}
+	log.Printf("Intermediate files: %d bytes, %d bytes with %s compression (%.1f%% saved)\n",
+		rawBytes, compressedBytes, sorter.codec.name, savedPercent(rawBytes, compressedBytes))
 
-	return &emptypb.Empty{}, nil
+	return manifest, nil
 }
 
 func (ms *MapperServer) InitReduce(ctx context.Context, input *InitReduceInput) (*emptypb.Empty, error) {
-	// read all the files from the mapperRootPath
+	// send the partition files of the map tasks to their reducers
 	log.Printf("Starting init reduce\n")
-	files, _ := os.ReadDir(mapperRootPath)
-	bufferFiles := []string{}
-
-	for _, file := range files {
-		fileName := file.Name()
-		if strings.Contains(fileName, "bucket") {
-			log.Printf("Loading file %s\n", fileName)
-			bufferFiles = append(bufferFiles, fileName)
-		}
+	codec, err := lookupCodec(input.Compression)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return &emptypb.Empty{}, err
 	}
-
 	unsecureOpt := grpc.WithTransportCredentials(insecure.NewCredentials())
 	blockingOpt := grpc.WithBlock()
 
@@ -114,9 +273,15 @@ func (ms *MapperServer) InitReduce(ctx context.Context, input *InitReduceInput)
 	// 	// defer connPool[i].Close()
 	// }
 
-	log.Printf("Buffer Files: %d\n", len(bufferFiles))
-	for _, fileName := range bufferFiles {
-		bucket, _ := strconv.Atoi(string([]rune(fileName)[len(fileName) - 5])) // *_bucket.bin | *_0.bin
+	log.Printf("Buffer Files: %d\n", len(input.Files))
+	// corrupt files are not sent, the master runs their map task again
+	corrupt := []string{}
+	var corruptErr error
+	for _, file := range input.Files {
+		fileName, bucket := filepath.Base(file.FileName), int(file.Partition)
+		if bucket >= len(input.Ports) {
+			return &emptypb.Empty{}, fmt.Errorf("no reducer for partition %d of %s", bucket, fileName)
+		}
 		log.Printf("Reading bucket %d files: %s\n", bucket, fileName)
 		conn, err := grpc.Dial(fmt.Sprintf("localhost:%s", input.Ports[bucket]), unsecureOpt, blockingOpt)
 		if err != nil {
@@ -126,33 +291,117 @@ func (ms *MapperServer) InitReduce(ctx context.Context, input *InitReduceInput)
 		}
 		defer conn.Close()
 		rc := NewReducerServiceClient(conn)
-		ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+		// no deadline, the file is streamed in chunks however large it is
+		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		data, err := os.ReadFile(mapperRootPath + "/" + fileName)
+		log.Printf("Sending intermediate data to reducer at port: %s\n", input.Ports[bucket])
+		err = sendIntermediateFile(ctx, rc, file, codec)
+		var corruptFile *corruptFileError
+		if errors.As(err, &corruptFile) {
+			log.Printf("Error: %v\n", err)
+			corrupt = append(corrupt, fileName)
+			corruptErr = err
+			os.Remove(mapperRootPath + "/" + fileName)
+			continue
+		}
 		if err != nil {
-			log.Printf("Error reading intermediate file: %s\n", fileName)
+			log.Printf("Error sending intermediate data to the reducer at %s\n", input.Ports[bucket])
+			return &emptypb.Empty{}, err
+		}
+		log.Printf("Sent intermediate data to reducer at port: %s\n", input.Ports[bucket])
+		// intermediate files are sent once
+		os.Remove(mapperRootPath + "/" + fileName)
+	}
+
+	if len(corrupt) > 0 {
+		return &emptypb.Empty{}, corruptStatus(corruptErr, corrupt...)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// streams the records of the intermediate file to the reducer
+// in chunks of at most chunkRecords records or chunkBytes bytes,
+// chunks are compressed with the codec of the file. The file is
+// verified against its manifest before the last chunk, the stream
+// is cancelled when it is corrupt
+func sendIntermediateFile(ctx context.Context, rc ReducerServiceClient, file *PartitionFile, codec *codec) error {
+	fileName := filepath.Base(file.FileName)
+	reader, err := openRecordFile(mapperRootPath + "/" + fileName, codec)
+	if err != nil {
+		log.Printf("Error reading intermediate file: %s\n", fileName)
+		return err
+	}
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := rc.SendIntermediateData(ctx, codec.callOptions()...)
+	if err != nil {
+		return err
+	}
+	chunk := &IntermediateChunk{FileName: fileName, Compression: codec.name}
+	size := 0
+	for {
+		kv, err := reader.Next()
+		if err == io.EOF {
 			break
 		}
-		payload := &KvPairs{}
-		err = proto.Unmarshal(data, payload)
 		if err != nil {
 			log.Printf("Error deserializing proto data of file: %s\n", fileName)
-			break
+			return err
+		}
+		chunk.Data = append(chunk.Data, kv)
+		size += proto.Size(kv)
+		if len(chunk.Data) >= chunkRecords || size >= chunkBytes {
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			chunk = &IntermediateChunk{FileName: fileName, Compression: codec.name}
+			size = 0
 		}
+	}
+	if err := reader.Verify(file.Records, file.Checksum); err != nil {
+		return err
+	}
+	// the last chunk is sent even when empty so that
+	// the reducer creates the file
+	if err := stream.Send(chunk); err != nil {
+		return err
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
 
-		log.Printf("Sending intermediate data to reducer at port: %s\n", input.Ports[bucket])
-		_, err = rc.SendIntermediateData(ctx, &IntermediateData{FileName: fileName, Data: payload})
+// runs the combiner of the job on a group of pairs with equal
+// keys, the group is returned as it is when there is no combiner
+func combine(job *Job, key string, group []*KeyValue, ctx *TaskContext) []*KeyValue {
+	if job.CombineValues != nil {
+		values := make([]*Value, 0, len(group))
+		for _, kv := range group {
+			values = append(values, valueOf(kv))
+		}
+		value, err := job.CombineValues(key, values, ctx)
 		if err != nil {
-			log.Printf("Error sending intermediate data to the reducer at %s\n", input.Ports[bucket])
+			// the pairs are sent as they are, reducers will see the error
+			log.Printf("Error running combiner on key %s: %v\n", key, err)
+			return group
 		}
-		log.Printf("Sent intermediate data to reducer at port: %s\n", input.Ports[bucket])
+		return []*KeyValue{{Key: key, Typed: value}}
 	}
 
-	return &emptypb.Empty{}, nil
+	if job.Combine != nil {
+		values := make([]string, 0, len(group))
+		for _, kv := range group {
+			values = append(values, textOf(kv))
+		}
+		return []*KeyValue{{Key: key, Value: job.Combine(key, values, ctx)}}
+	}
+	return group
 }
 
 func InitMapperFileSystem(port string) (error) {
+	mapperPort = port
 	mapperRootPath = fmt.Sprintf("./mappers/m%s", port)
 	// os.RemoveAll(mapperRootPath)
 	err := os.MkdirAll(mapperRootPath, 0755)
@@ -176,30 +425,79 @@ func InitMapperLogs() error {
 }
 
 // we do not use key in this function
-func wcMap(_key, value string) *KvPairs {
+func wcMap(_key, value string, _ *TaskContext) (*KvPairs, error) {
 	// spliting into words
 	words := strings.FieldsFunc(value, func(r rune) bool { return !unicode.IsLetter(r) })
 	// emit intermediate key value pairs
 	kvPairs := &KvPairs{}
 	for _, word := range words {
-		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: word, Value: "1"})
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: word, Typed: IntValue(1)})
 	}
 
-	return kvPairs
+	return kvPairs, nil
 }
 
-func invIndexMap(key, value string) *KvPairs {
+func invIndexMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
 	// spliting into words
-	// key is the input file name
 	words := strings.FieldsFunc(value, func(r rune) bool { return !unicode.IsLetter(r) })
 	// emit intermediate key value pairs
 	kvPairs := &KvPairs{}
 	// generates word: fileName
 	for _, word := range words {
-		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: word, Value: key})
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: word, Value: ctx.FileName})
+	}
+
+	return kvPairs, nil
+}
+
+// positional inverted index of a whole file
+// emits word: "line:offset:fileName" for every occurrence
+func invIndexPosMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	kvPairs := &KvPairs{}
+	for _, tok := range tokenizeWithPositions(value) {
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{
+			Key: tok.word,
+			Value: fmt.Sprintf("%d:%d:%s", tok.line, tok.offset, ctx.FileName),
+		})
+	}
+
+	return kvPairs, nil
+}
+
+// token is a word along with its 1-based line number
+// and the byte offset of the word within that line
+type token struct {
+	word string
+	line int
+	offset int
+}
+
+// splits the text into words the same way as wcMap
+// but keeps track of where each word starts
+func tokenizeWithPositions(text string) []token {
+	tokens := []token{}
+	line, lineStart, wordStart := 1, 0, -1
+	for i, r := range text {
+		if unicode.IsLetter(r) {
+			if wordStart < 0 {
+				wordStart = i
+			}
+			continue
+		}
+		if wordStart >= 0 {
+			tokens = append(tokens, token{word: text[wordStart:i], line: line, offset: wordStart - lineStart})
+			wordStart = -1
+		}
+		if r == '\n' {
+			line++
+			lineStart = i + 1
+		}
+	}
+	if wordStart >= 0 {
+		tokens = append(tokens, token{word: text[wordStart:], line: line, offset: wordStart - lineStart})
 	}
 
-	return kvPairs
+	return tokens
 }
 
 func hashWordToBucket(word string) int {
@@ -23,7 +23,7 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type MapperServiceClient interface {
-	RunMap(ctx context.Context, in *RunMapInput, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RunMap(ctx context.Context, opts ...grpc.CallOption) (MapperService_RunMapClient, error)
 	InitReduce(ctx context.Context, in *InitReduceInput, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
@@ -35,13 +35,38 @@ func NewMapperServiceClient(cc grpc.ClientConnInterface) MapperServiceClient {
 	return &mapperServiceClient{cc}
 }
 
-func (c *mapperServiceClient) RunMap(ctx context.Context, in *RunMapInput, opts ...grpc.CallOption) (*emptypb.Empty, error) {
-	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, "/services.MapperService/RunMap", in, out, opts...)
+func (c *mapperServiceClient) RunMap(ctx context.Context, opts ...grpc.CallOption) (MapperService_RunMapClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MapperService_ServiceDesc.Streams[0], "/services.MapperService/RunMap", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &mapperServiceRunMapClient{stream}
+	return x, nil
+}
+
+type MapperService_RunMapClient interface {
+	Send(*RunMapInput) error
+	CloseAndRecv() (*MapManifest, error)
+	grpc.ClientStream
+}
+
+type mapperServiceRunMapClient struct {
+	grpc.ClientStream
+}
+
+func (x *mapperServiceRunMapClient) Send(m *RunMapInput) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *mapperServiceRunMapClient) CloseAndRecv() (*MapManifest, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(MapManifest)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
 func (c *mapperServiceClient) InitReduce(ctx context.Context, in *InitReduceInput, opts ...grpc.CallOption) (*emptypb.Empty, error) {
@@ -57,7 +82,7 @@ func (c *mapperServiceClient) InitReduce(ctx context.Context, in *InitReduceInpu
 // All implementations must embed UnimplementedMapperServiceServer
 // for forward compatibility
 type MapperServiceServer interface {
-	RunMap(context.Context, *RunMapInput) (*emptypb.Empty, error)
+	RunMap(MapperService_RunMapServer) error
 	InitReduce(context.Context, *InitReduceInput) (*emptypb.Empty, error)
 	mustEmbedUnimplementedMapperServiceServer()
 }
@@ -66,8 +91,8 @@ type MapperServiceServer interface {
 type UnimplementedMapperServiceServer struct {
 }
 
-func (UnimplementedMapperServiceServer) RunMap(context.Context, *RunMapInput) (*emptypb.Empty, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method RunMap not implemented")
+func (UnimplementedMapperServiceServer) RunMap(MapperService_RunMapServer) error {
+	return status.Errorf(codes.Unimplemented, "method RunMap not implemented")
 }
 func (UnimplementedMapperServiceServer) InitReduce(context.Context, *InitReduceInput) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InitReduce not implemented")
@@ -85,22 +110,30 @@ func RegisterMapperServiceServer(s grpc.ServiceRegistrar, srv MapperServiceServe
 	s.RegisterService(&MapperService_ServiceDesc, srv)
 }
 
-func _MapperService_RunMap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RunMapInput)
-	if err := dec(in); err != nil {
+func _MapperService_RunMap_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MapperServiceServer).RunMap(&mapperServiceRunMapServer{stream})
+}
+
+type MapperService_RunMapServer interface {
+	SendAndClose(*MapManifest) error
+	Recv() (*RunMapInput, error)
+	grpc.ServerStream
+}
+
+type mapperServiceRunMapServer struct {
+	grpc.ServerStream
+}
+
+func (x *mapperServiceRunMapServer) SendAndClose(m *MapManifest) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *mapperServiceRunMapServer) Recv() (*RunMapInput, error) {
+	m := new(RunMapInput)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(MapperServiceServer).RunMap(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/services.MapperService/RunMap",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(MapperServiceServer).RunMap(ctx, req.(*RunMapInput))
-	}
-	return interceptor(ctx, in, info, handler)
+	return m, nil
 }
 
 func _MapperService_InitReduce_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
@@ -128,15 +161,17 @@ var MapperService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "services.MapperService",
 	HandlerType: (*MapperServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "RunMap",
-			Handler:    _MapperService_RunMap_Handler,
-		},
 		{
 			MethodName: "InitReduce",
 			Handler:    _MapperService_InitReduce_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RunMap",
+			Handler:       _MapperService_RunMap_Handler,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "services/mapper.proto",
 }
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// partitions of two digit reducers are routed by the manifest,
+// not by parsing the bucket file names
+func TestRunMapMoreThanTenReducers(t *testing.T) {
+	const nReducers = 12
+	oldRootPath, oldPort, oldReducers := mapperRootPath, mapperPort, MasterConfig.Client.NReducers
+	t.Cleanup(func() {
+		mapperRootPath, mapperPort, MasterConfig.Client.NReducers = oldRootPath, oldPort, oldReducers
+	})
+	mapperRootPath = t.TempDir()
+	mapperPort = "35468"
+
+	// words are letters only, distinct words of two letters
+	words := []string{}
+	for i := 0; i < 500; i++ {
+		words = append(words, string(rune('a'+i/26))+string(rune('a'+i%26)))
+	}
+	manifest, err := runMap(&RunMapInput{
+		TaskId:    3,
+		NReducers: nReducers,
+		Fn:        "wc",
+		FileName:  "words.txt",
+		FileData:  []byte(strings.Join(words, " ")),
+	})
+	if err != nil {
+		t.Fatalf("runMap: %v", err)
+	}
+	if len(manifest.Partitions) != nReducers {
+		t.Fatalf("%d partition files, want %d", len(manifest.Partitions), nReducers)
+	}
+
+	var records int64
+	for _, file := range manifest.Partitions {
+		if _, err := os.Stat(filepath.Join(mapperRootPath, file.FileName)); err != nil {
+			t.Errorf("partition %d: %v", file.Partition, err)
+		}
+		records += file.Records
+	}
+	if records != int64(len(words)) {
+		t.Errorf("%d records in the partition files, want %d", records, len(words))
+	}
+
+	MasterConfig.Client.NReducers = nReducers
+	reducerFiles := partitionFiles([]*MapManifest{manifest})
+	for _, partition := range []int{10, 11} {
+		files := reducerFiles[partition]
+		if len(files) != 1 {
+			t.Fatalf("reducer %d gets %d files, want 1", partition, len(files))
+		}
+		file := files[0]
+		if int(file.Partition) != partition {
+			t.Errorf("reducer %d gets the file of partition %d", partition, file.Partition)
+		}
+		if want := fmt.Sprintf("wc_task_3_bucket_%d.bin", partition); file.FileName != want {
+			t.Errorf("reducer %d gets %s, want %s", partition, file.FileName, want)
+		}
+		if file.Records == 0 {
+			t.Errorf("partition %d is empty", partition)
+		}
+	}
+}
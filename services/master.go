@@ -1,15 +1,18 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"hash/crc32"
 	"io"
-	"io/fs"
 	"log"
+	"math/rand"
 	"os"
 	"os/exec"
-	"strings"
+	"sort"
+	"strconv"
 	"sync"
-	"time"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -35,6 +38,10 @@ type Config struct {
 		MaxAllowed int `json:"maxAllowed"`
 		Ports []string `json:"ports"`
 	} `json:"reducers"`
+	Plugins struct {
+		Dir string `json:"dir"`
+	} `json:"plugins"`
+	Storage StorageConfig `json:"storage"`
 }
 
 var MasterConfig Config
@@ -68,89 +75,397 @@ func (s *MasterServer) RunMapRd(stream MasterService_RunMapRdServer) (error) {
 	// listen to the stream
 	log.Printf("Receiving ")
 	fn := ""
+	var params map[string]string
+	var pipeline *Pipeline
+	var stages []*Stage
+	inputFiles := []*stageInput{}
 	for {
 		input, err := stream.Recv()
-		// read function type
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// read function type and job parameters
 		if len(fn) == 0 {
 			log.Printf("Input function: %s\n", input.Fn)
 			fn = input.Fn
+			params = input.Params
+			pipeline = input.Pipeline
+			if pipeline == nil {
+				pipeline, err = lookupPipeline(fn)
+				if err != nil {
+					log.Printf("Error: %v\n", err)
+					return err
+				}
+			}
+			stages, err = schedulePipeline(pipeline)
+			if err != nil {
+				log.Printf("Error: %v\n", err)
+				return err
+			}
+			_, err = outputExt(params)
+			if err != nil {
+				log.Printf("Error: %v\n", err)
+				return err
+			}
 		}
-		if err == io.EOF {
-			break
+
+		// input files are read from storage by the mappers
+		if len(input.Inputs) > 0 {
+			files, err := resolveInputs(input.Inputs, params)
+			if err != nil {
+				log.Printf("Error resolving input files: %v\n", err)
+				return err
+			}
+			log.Printf("%d input files in %v\n", len(files), input.Inputs)
+			inputFiles = append(inputFiles, files...)
+		}
+	}
+
+	if params == nil {
+		params = map[string]string{}
+	}
+	// number of documents for jobs like tfidf
+	params["nInputFiles"] = strconv.Itoa(len(inputFiles))
+
+	// outputs are written to the output directory in storage, the
+	// reducers of the final stages write their files there directly.
+	// Without it they are sent back and written to ./output
+	outputDir := params["output"]
+	if len(outputDir) == 0 {
+		outputDir = "./output"
+		os.RemoveAll(outputDir)
+		os.MkdirAll(outputDir, 0755)
+	}
+
+	// reducer outputs of a stage are the map inputs of the stages
+	// depending on it, only the outputs of the final stages are returned
+	finals := finalStages(pipeline)
+	stageOutputs := map[string][]*stageInput{}
+	outputs := []*FileOutput{}
+	for i, stage := range stages {
+		log.Printf("Running stage %d/%d: %s (%s)\n", i + 1, len(stages), stage.Name, stage.Fn)
+		stageInputs := inputFiles
+		if len(stage.Inputs) > 0 {
+			stageInputs = []*stageInput{}
+			for _, name := range stage.Inputs {
+				stageInputs = append(stageInputs, stageOutputs[name]...)
+			}
+		}
+		job, _ := lookupJob(stage.Fn)
+		outputPrefix := ""
+		if finals[stage.Name] && len(params["output"]) > 0 {
+			outputPrefix = joinURI(outputDir, "")
+			if len(finals) > 1 {
+				outputPrefix += stage.Name + "_"
+			}
+		}
+		// only the final outputs are formatted, the next stages read text
+		runParams := stageParams(params, stage)
+		if !finals[stage.Name] {
+			runParams = textOutputParams(runParams)
 		}
+		files, err := runIterations(stage, job, runParams, stageInputs, outputPrefix)
 		if err != nil {
+			log.Printf("Error running stage %s: %v\n", stage.Name, err)
 			return err
 		}
 
-		err = os.WriteFile(masterRootPath + "/input_" + input.File.Name, input.File.Data, 0655)
+		if finals[stage.Name] {
+			for _, file := range files {
+				// outputs of the final stages are told apart by the stage name
+				if len(finals) > 1 {
+					file.Name = stage.Name + "_" + file.Name
+				}
+				outputs = append(outputs, file)
+			}
+			continue
+		}
+
+		for _, file := range files {
+			name := stage.Name + "_" + file.Name
+			filePath := masterRootPath + "/" + name
+			err = copyOutput(file, filePath)
+			if err != nil {
+				log.Printf("Error writing stage output: %v\n", err)
+				return err
+			}
+			// outputs of a stage are a dataset named after the stage
+			stageOutputs[stage.Name] = append(stageOutputs[stage.Name], &stageInput{name: name, path: filePath, dataset: stage.Name})
+		}
+	}
+
+	for _, file := range outputs {
+		uri := joinURI(outputDir, file.Name)
+		if file.Uri == uri {
+			log.Printf("Output file written to %s\n", file.Uri)
+			continue
+		}
+		err := copyOutput(file, uri)
 		if err != nil {
-			log.Printf("Error writing input files: %v\n", err)
-			return err
+			log.Printf("Error writing the returned output file: %s\n", file.Name)
+			log.Printf("Error: %v\n", err)
 		}
 	}
 
-	// send the files to each map job
-	// for each file
-	files, err := os.ReadDir(masterRootPath)
-	if err != nil {
-		log.Printf("Error reading root: %v\n", err)
-		return stream.SendAndClose(&Log{})
+	return stream.SendAndClose(&Log{})
+}
+
+// input file of a stage stored on the master, or
+// in storage when uri is set
+type stageInput struct {
+	// name given to the map function
+	name string
+	path string
+	uri string
+	dataset string
+}
+
+func (file *stageInput) open() (io.ReadCloser, error) {
+	if len(file.uri) > 0 {
+		return openURI(file.uri)
+	}
+	return os.Open(file.path)
+}
+
+// runs the stage once, or for iterative stages reruns it on its
+// own outputs until the convergence counter is zero or maxIterations.
+// Iterations send their outputs back in text, they are the next
+// inputs, and the outputs of the last one are formatted by the master
+func runIterations(stage *Stage, job *Job, params map[string]string, inputFiles []*stageInput, outputPrefix string) ([]*FileOutput, error) {
+	if stage.MaxIterations <= 1 {
+		return runStage(stage, job, params, inputFiles, outputPrefix)
+	}
+
+	var outputs []*FileOutput
+	for i := 1; i <= int(stage.MaxIterations); i++ {
+		log.Printf("Stage %s iteration %d/%d\n", stage.Name, i, stage.MaxIterations)
+		var err error
+		outputs, err = runStage(stage, job, textOutputParams(params), inputFiles, "")
+		if err != nil {
+			return nil, err
+		}
+
+		counters := sumCounters(outputs)
+		log.Printf("Stage %s iteration %d counters: %v\n", stage.Name, i, counters)
+		if len(stage.ConvergenceCounter) > 0 && counters[stage.ConvergenceCounter] == 0 {
+			log.Printf("Stage %s converged after %d iterations\n", stage.Name, i)
+			break
+		}
+
+		// outputs of this iteration are the inputs of the next one
+		inputFiles = []*stageInput{}
+		for _, file := range outputs {
+			name := fmt.Sprintf("%s_iter%d_%s", stage.Name, i, file.Name)
+			filePath := masterRootPath + "/" + name
+			err = copyOutput(file, filePath)
+			if err != nil {
+				log.Printf("Error writing stage output: %v\n", err)
+				return nil, err
+			}
+			inputFiles = append(inputFiles, &stageInput{name: name, path: filePath, dataset: stage.Name})
+		}
+	}
+
+	for i, file := range outputs {
+		formatted, err := formatOutput(file, params, stage.Name)
+		if err != nil {
+			return nil, err
+		}
+		outputs[i] = formatted
+	}
+	return outputs, nil
+}
+
+// counters of the reducer outputs summed by name
+func sumCounters(outputs []*FileOutput) map[string]int64 {
+	counters := map[string]int64{}
+	for _, file := range outputs {
+		for name, value := range file.Counters {
+			counters[name] += value
+		}
 	}
+	return counters
+}
 
+// runs a single map reduce pass of the stage function over the input
+// files and returns the output files of the reducers. Reducers write
+// their files to storage at outputPrefix + name when it is set, or
+// else send them to the master which keeps them under the stage name
+func runStage(stage *Stage, job *Job, params map[string]string, inputFiles []*stageInput, outputPrefix string) ([]*FileOutput, error) {
+	fn := stage.Fn
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var stageErr error
+	// keeps the first error of the tasks
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stageErr == nil {
+			stageErr = err
+		}
+	}
+
 	unsecureOpt := grpc.WithTransportCredentials(insecure.NewCredentials())
 	blockingOpt := grpc.WithBlock()
 
-	inputFiles := []fs.DirEntry{}
-	for _, file := range files {
-		fileName := file.Name()
-		if strings.Contains(fileName, "input") {
-			inputFiles = append(inputFiles, file)
+	// input files and intermediate data are compressed with the codec
+	codec, err := lookupCodec(params["compression"])
+	if err != nil {
+		return nil, err
+	}
+	ext, err := outputExt(params)
+	if err != nil {
+		return nil, err
+	}
+	if job.Prepare != nil {
+		params, err = job.Prepare(inputFiles, params)
+		if err != nil {
+			log.Printf("Error preparing the job: %v\n", err)
+			return nil, err
 		}
 	}
-	
-	for i, file := range inputFiles {
-		// at max we can send files to 1 mapper at a time
-		mapperIndex := i % MasterConfig.Client.NMappers
-		wg.Add(1)
-		go func(i int, file fs.DirEntry) {
-			// create a connection
-			mapperPort := MasterConfig.Mappers.Ports[mapperIndex]
-			log.Printf("Sending file %s task %d to mapper %s", file.Name(), i, mapperPort)
-			conn, err := grpc.Dial(fmt.Sprintf("localhost:%s", mapperPort), unsecureOpt, blockingOpt)
-			if err != nil {
-				// mapper connection failed
-				// maybe its down?
-				// handle faults here
-				log.Print("Error: ", err)
-				return
+	// outputs of jobs with a merge step are merged by the master,
+	// the reducers send text outputs back and the merged output is formatted
+	reduceParams := params
+	if job.Merge != nil {
+		outputPrefix = ""
+		reduceParams = textOutputParams(params)
+	}
+
+	// files of the broadcast dataset are sent to every map task
+	// instead of being mapped
+	sideInputs := []*stageInput{}
+	if broadcast := params["broadcast"]; len(broadcast) > 0 {
+		mapInputs := []*stageInput{}
+		for _, file := range inputFiles {
+			if file.dataset != broadcast {
+				mapInputs = append(mapInputs, file)
+				continue
 			}
-			
-			defer conn.Close()
-			defer wg.Done()
-			
-			mc := NewMapperServiceClient(conn)
-			ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-			defer cancel()
+			sideInputs = append(sideInputs, file)
+		}
+		if len(sideInputs) == 0 {
+			return nil, fmt.Errorf("no input files in broadcast dataset %s", broadcast)
+		}
+		// ex: a map side self join, the broadcast dataset is not mapped
+		if len(mapInputs) == 0 {
+			return nil, fmt.Errorf("no input files to map besides the broadcast dataset %s", broadcast)
+		}
+		log.Printf("Broadcasting %d files of dataset %s\n", len(sideInputs), broadcast)
+		inputFiles = mapInputs
+	}
+
+	var splits []string
+	if job.RangePartition {
+		var err error
+		splits, err = sampleSplits(job, inputFiles, params, MasterConfig.Client.NReducers)
+		if err != nil {
+			log.Printf("Error sampling input files: %v\n", err)
+			return nil, err
+		}
+		log.Printf("Range partition splits: %q\n", splits)
+	}
+	
+	// runs map task i on a mapper and returns its manifest, partitions
+	// limits the partition files written when the task is run again
+	runMapTask := func(i int, mapperIndex int, partitions []int32) (*MapManifest, error) {
+		file := inputFiles[i]
+		// create a connection
+		mapperPort := MasterConfig.Mappers.Ports[mapperIndex]
+		log.Printf("Sending file %s task %d to mapper %s", file.name, i, mapperPort)
+		conn, err := grpc.Dial(fmt.Sprintf("localhost:%s", mapperPort), unsecureOpt, blockingOpt)
+		if err != nil {
+			// mapper connection failed
+			// maybe its down?
+			// handle faults here
+			return nil, err
+		}
+		
+		defer conn.Close()
+		
+		mc := NewMapperServiceClient(conn)
+		// no deadline, the call streams the whole input file and runs
+		// the map task. A mapper going down breaks the connection
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 
-			fileData, err := os.ReadFile(masterRootPath + "/" + file.Name())
+		stream, err := mc.RunMap(ctx, codec.callOptions()...)
+		if err != nil {
+			return nil, err
+		}
+		send := func(input *RunMapInput) error {
+			err := stream.Send(input)
+			if err == io.EOF {
+				// mapper closed the stream, the error is returned on receive
+				_, err = stream.CloseAndRecv()
+			}
+			return err
+		}
+		// task fields go on the first message, the input file
+		// and the side inputs follow in chunks
+		err = send(&RunMapInput{
+			TaskId: int32(i),
+			NReducers: int32(MasterConfig.Client.NReducers),
+			Fn: fn,
+			FileName: file.name,
+			Params: params,
+			Splits: splits,
+			Dataset: file.dataset,
+			Partitions: partitions,
+			FileUri: file.uri,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// files in storage are read by the mapper
+		if len(file.uri) == 0 {
+			err = readChunks(file.path, func(piece []byte) error {
+				return send(&RunMapInput{FileData: piece})
+			})
 			if err != nil {
-				log.Printf("Error reading filedata: %s\n", file.Name())
-				return
+				log.Printf("Error sending filedata: %s\n", file.path)
+				return nil, err
 			}
-			runMapInput := &RunMapInput{
-				TaskId: int32(i),
-				NReducers: int32(MasterConfig.Client.NReducers),
-				Fn: fn,
-				FileName: file.Name(),
-				FileData: fileData,
+		}
+		for _, side := range sideInputs {
+			if len(side.uri) > 0 {
+				err = send(&RunMapInput{SideInputs: []*FileInput{{Name: side.name, Dataset: side.dataset, Uri: side.uri}}})
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			err = readChunks(side.path, func(piece []byte) error {
+				return send(&RunMapInput{SideInputs: []*FileInput{{Name: side.name, Dataset: side.dataset, Data: piece}}})
+			})
+			if err != nil {
+				log.Printf("Error sending side input: %s\n", side.path)
+				return nil, err
 			}
-			_, err = mc.RunMap(ctx, runMapInput)
+		}
+		return stream.CloseAndRecv()
+	}
+
+	// manifests of the map tasks, by task id
+	manifests := make([]*MapManifest, len(inputFiles))
+	for i := range inputFiles {
+		// at max we can send files to 1 mapper at a time
+		mapperIndex := i % MasterConfig.Client.NMappers
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			manifest, err := runMapTask(i, mapperIndex, nil)
 			if err != nil {
 				// map job failed, handle fault
 				log.Print("Error: ", err)
+				fail(err)
+				return
 			}
-		}(i, file)
+			manifests[i] = manifest
+		}(i)
 
 		if mapperIndex + 1 == MasterConfig.Client.NMappers {
 			wg.Wait()
@@ -158,82 +473,444 @@ func (s *MasterServer) RunMapRd(stream MasterService_RunMapRdServer) (error) {
 	}
 
 	wg.Wait()
+	if stageErr != nil {
+		return nil, stageErr
+	}
 
 	// all map tasks are done
 	log.Printf("All map tasks are done!\n")
-	
-	// start init reduce tasks
-	// send the intermediate data to reducers
-	log.Printf("Starting reduce tasks\n")
-	for i := 0; i < MasterConfig.Client.NMappers; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			mapperPort := MasterConfig.Mappers.Ports[i]
-			log.Printf("Dailing mapper at port %s\n", mapperPort)
-			conn, err := grpc.Dial(fmt.Sprintf("localhost:%s", mapperPort), unsecureOpt, blockingOpt)
-			if err != nil {
-				log.Printf("Error: %v\n", err)
+	reducerFiles := partitionFiles(manifests)
+
+	// files to send to the reducers and reducers to run, a corrupt
+	// partition file is produced again by its map task and only the
+	// new file is sent, to the reducers that did not finish yet
+	files := []*PartitionFile{}
+	for _, manifest := range manifests {
+		files = append(files, manifest.Partitions...)
+	}
+	outputs := make([]*FileOutput, MasterConfig.Client.NReducers)
+	for attempt := 0; ; attempt++ {
+		corrupt := map[string]bool{}
+		// corrupt files fail the call with their names, other errors fail the stage
+		failCall := func(err error) {
+			fileNames := corruptFiles(err)
+			if len(fileNames) == 0 {
+				fail(err)
 				return
 			}
-			defer conn.Close()
+			mu.Lock()
+			defer mu.Unlock()
+			for _, fileName := range fileNames {
+				corrupt[fileName] = true
+			}
+		}
 
-			mc := NewMapperServiceClient(conn)
-			ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-			defer cancel()
+		// start init reduce tasks
+		// send the intermediate data to reducers
+		log.Printf("Starting reduce tasks\n")
+		mapperFiles := filesByLocation(files)
+		for i := 0; i < MasterConfig.Client.NMappers; i++ {
+			mapperPort := MasterConfig.Mappers.Ports[i]
+			if len(mapperFiles["localhost:" + mapperPort]) == 0 {
+				continue
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				log.Printf("Dailing mapper at port %s\n", mapperPort)
+				conn, err := grpc.Dial(fmt.Sprintf("localhost:%s", mapperPort), unsecureOpt, blockingOpt)
+				if err != nil {
+					log.Printf("Error: %v\n", err)
+					fail(err)
+					return
+				}
+				defer conn.Close()
 
-			_, err = mc.InitReduce(ctx, &InitReduceInput{Ports: MasterConfig.Reducers.Ports})
-			if err != nil {
-				log.Printf("Error starting InitReduce on mapper port: %s\n", MasterConfig.Mappers.Ports[i])
-				log.Printf("Error: %v\n", err)
+				mc := NewMapperServiceClient(conn)
+				// no deadline, the mapper streams all of its partition files
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				_, err = mc.InitReduce(ctx, &InitReduceInput{
+					Ports: MasterConfig.Reducers.Ports,
+					Compression: codec.name,
+					Files: mapperFiles["localhost:" + mapperPort],
+				})
+				if err != nil {
+					log.Printf("Error starting InitReduce on mapper port: %s\n", MasterConfig.Mappers.Ports[i])
+					log.Printf("Error: %v\n", err)
+					failCall(err)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		if stageErr != nil {
+			return nil, stageErr
+		}
+
+		// reducers run once all of their files were sent
+		if len(corrupt) == 0 {
+			log.Printf("Signaling reducers to start reduce tasks!\n")
+
+			for i := 0; i < MasterConfig.Client.NReducers; i++ {
+				if outputs[i] != nil {
+					// finished in a previous attempt
+					continue
+				}
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					reducerPort := MasterConfig.Reducers.Ports[i]
+					conn, err := grpc.Dial(fmt.Sprintf("localhost:%s", reducerPort), unsecureOpt, blockingOpt)
+					if err != nil {
+						log.Printf("Error: %v\n", err)
+						fail(err)
+						return
+					}
+					defer conn.Close()
+
+					log.Printf("Signaling reducer at port: %s", MasterConfig.Reducers.Ports[i])
+					rc := NewReducerServiceClient(conn)
+					// no deadline, the reduce task runs as long as its input needs
+					ctx, cancel := context.WithCancel(context.Background())
+					defer cancel()
+
+					reduceInput := &RunReduceInput{Fn: fn, Params: reduceParams, Files: reducerFiles[i]}
+					if len(outputPrefix) > 0 {
+						reduceInput.OutputUri = fmt.Sprintf("%sout%s%s", outputPrefix, reducerPort, ext)
+					}
+					// final stages of a pipeline may run the same function, their
+					// outputs are only copied to ./output once all stages ran
+					spoolPath := fmt.Sprintf("%s/spool_%s_out%s", masterRootPath, stage.Name, reducerPort)
+					file, err := receiveOutputFile(ctx, rc, reduceInput, spoolPath)
+					if err != nil {
+						log.Printf("Error starting reduce on reducer port: %s\n", MasterConfig.Reducers.Ports[i])
+						log.Printf("Error: %v\n", err)
+						failCall(err)
+						return
+					}
+					outputs[i] = file
+				}(i)
 			}
-		}(i)
-	}
 
-	wg.Wait()
+			// all reducers finished their task
+			wg.Wait()
+			if stageErr != nil {
+				return nil, stageErr
+			}
+		}
 
-	log.Printf("Signaling reducers to start reduce tasks!\n")
-	
-	// cleaning output folder
-	basePath := "./output"
-	os.RemoveAll(basePath)
-	os.MkdirAll(basePath, 0755)
+		if len(corrupt) == 0 {
+			break
+		}
+		if attempt == maxCorruptRetries {
+			return nil, fmt.Errorf("partition files still corrupt after %d map retries: %v", maxCorruptRetries, corrupt)
+		}
 
-	for i := 0; i < MasterConfig.Client.NReducers; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			reducerPort := MasterConfig.Reducers.Ports[i]
-			conn, err := grpc.Dial(fmt.Sprintf("localhost:%s", reducerPort), unsecureOpt, blockingOpt)
+		// runs the map tasks of the corrupt files again, on
+		// another mapper, writing the corrupt partitions only
+		taskPartitions := map[int32][]int32{}
+		for _, manifest := range manifests {
+			for _, file := range manifest.Partitions {
+				if corrupt[file.FileName] {
+					taskPartitions[manifest.TaskId] = append(taskPartitions[manifest.TaskId], file.Partition)
+				}
+			}
+		}
+		log.Printf("Corrupt partition files %v, running map tasks again\n", corrupt)
+		files = []*PartitionFile{}
+		for task, partitions := range taskPartitions {
+			mapperIndex := (int(task) + attempt + 1) % MasterConfig.Client.NMappers
+			manifest, err := runMapTask(int(task), mapperIndex, partitions)
 			if err != nil {
-				log.Printf("Error: %v\n", err)
-				return
+				log.Print("Error: ", err)
+				return nil, err
 			}
-			defer conn.Close()
+			// the new files replace the corrupt ones
+			for _, file := range manifest.Partitions {
+				for j, old := range reducerFiles[file.Partition] {
+					if old.FileName == file.FileName {
+						reducerFiles[file.Partition][j] = file
+					}
+				}
+				for j, old := range manifests[task].Partitions {
+					if old.FileName == file.FileName {
+						manifests[task].Partitions[j] = file
+					}
+				}
+			}
+			files = append(files, manifest.Partitions...)
+		}
+	}
 
-			log.Printf("Signaling reducer at port: %s", MasterConfig.Reducers.Ports[i])
-			rc := NewReducerServiceClient(conn)
-			ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Second)
-			defer cancel()
+	if codec.name != "none" {
+		counters := sumCounters(outputs)
+		rawBytes, compressedBytes := counters[intermediateBytesCounter], counters[compressedBytesCounter]
+		log.Printf("Intermediate data of %s: %d bytes, %d bytes with %s compression (%.1f%% saved)\n",
+			fn, rawBytes, compressedBytes, codec.name, savedPercent(rawBytes, compressedBytes))
+	}
 
-			file, err := rc.RunReduce(ctx, &RunReduceInput{Fn: fn})
+	// jobs with a merge step produce a single output file
+	if job.Merge != nil {
+		log.Printf("Merging reducer outputs\n")
+		// merge steps get the reducer outputs in memory
+		for _, output := range outputs {
+			output.Data, err = readURI(output.Uri)
 			if err != nil {
-				log.Printf("Error starting reduce on reducer port: %s\n", MasterConfig.Reducers.Ports[i])
-				log.Printf("Error: %v\n", err)
+				log.Printf("Error reading reducer output: %v\n", err)
+				return nil, err
 			}
-			
-			err = os.WriteFile(basePath + "/" + file.Name, file.Data, 0666)
-			if err != nil {
-				log.Printf("Error writing the returned output file: %s\n", file.Name)
-				log.Printf("Error: %v\n", err)
+		}
+		merged, err := job.Merge(outputs, newTaskContext(params))
+		if err != nil {
+			log.Printf("Error merging reducer outputs: %v\n", err)
+			return nil, err
+		}
+		merged, err = formatOutput(merged, params, stage.Name)
+		if err != nil {
+			log.Printf("Error formatting the merged output: %v\n", err)
+			return nil, err
+		}
+		outputs = []*FileOutput{merged}
+	}
+
+	return outputs, nil
+}
+
+// groups the partition files of the map manifests by the reducer
+// of their partition, the size of every partition is logged to
+// spot skewed partitions
+func partitionFiles(manifests []*MapManifest) [][]*PartitionFile {
+	reducerFiles := make([][]*PartitionFile, MasterConfig.Client.NReducers)
+	for _, manifest := range manifests {
+		for _, file := range manifest.Partitions {
+			reducerFiles[file.Partition] = append(reducerFiles[file.Partition], file)
+		}
+	}
+
+	for partition, files := range reducerFiles {
+		var records, bytes int64
+		for _, file := range files {
+			records += file.Records
+			bytes += file.Bytes
+		}
+		log.Printf("Partition %d: %d files, %d records, %d bytes\n", partition, len(files), records, bytes)
+	}
+	return reducerFiles
+}
+
+// groups the partition files by the mapper holding them
+func filesByLocation(files []*PartitionFile) map[string][]*PartitionFile {
+	mapperFiles := map[string][]*PartitionFile{}
+	for _, file := range files {
+		mapperFiles[file.Location] = append(mapperFiles[file.Location], file)
+	}
+	return mapperFiles
+}
+
+// runs the reduce task and writes the streamed output file to
+// spoolPath, the output returned is the file at its Uri
+func receiveOutputFile(ctx context.Context, rc ReducerServiceClient, input *RunReduceInput, spoolPath string) (*FileOutput, error) {
+	stream, err := rc.RunReduce(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	spool, err := os.Create(spoolPath)
+	if err != nil {
+		return nil, err
+	}
+	defer spool.Close()
+	checksum := crc32.New(crc32cTable)
+	file := &FileOutput{}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		file.Name = chunk.Name
+		if chunk.Counters != nil {
+			file.Counters = chunk.Counters
+		}
+		file.Checksum = chunk.Checksum
+		file.Uri = chunk.Uri
+		checksum.Write(chunk.Data)
+		if _, err := spool.Write(chunk.Data); err != nil {
+			return nil, err
+		}
+	}
+	// the checksum is set on the last chunk, files
+	// written to storage are not sent
+	if len(file.Uri) > 0 {
+		spool.Close()
+		os.Remove(spoolPath)
+		return file, nil
+	}
+	if err := spool.Close(); err != nil {
+		return nil, err
+	}
+	if checksum.Sum32() != file.Checksum {
+		return nil, fmt.Errorf("corrupt output file %s: checksum %08x, expected %08x", file.Name, checksum.Sum32(), file.Checksum)
+	}
+	file.Uri = spoolPath
+	return file, nil
+}
+
+// contents of an output file, in memory (Data) when it was made by
+// the master or else in the file at its Uri
+func openOutput(file *FileOutput) (io.ReadCloser, error) {
+	if len(file.Uri) == 0 {
+		return io.NopCloser(bytes.NewReader(file.Data)), nil
+	}
+	return openURI(file.Uri)
+}
+
+// copies the output file to the uri
+func copyOutput(file *FileOutput, uri string) error {
+	reader, err := openOutput(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	writer, err := createURI(uri)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// reads the file in chunks of chunkBytes, an empty
+// file is a single empty chunk
+func readChunks(filePath string, fn func(piece []byte) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buf := make([]byte, chunkBytes)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(file, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if n > 0 || first {
+			if err := fn(buf[:n]); err != nil {
+				return err
 			}
-		}(i)
+		}
+		if n < len(buf) {
+			return nil
+		}
 	}
+}
 
-	// all reducers finished their task
-	wg.Wait()
+// number of keys sampled from the map output of each input file
+const samplesPerFile = 1000
 
-	return stream.SendAndClose(&Log{})
+// whole input files are mapped by the sampler in blocks of lines of
+// about sampleBlockBytes, not in one piece
+const sampleBlockBytes = 1 << 20
+
+// samples the map output keys of every input file and picks
+// nReducers - 1 evenly spaced keys from them as range boundaries
+func sampleSplits(job *Job, inputFiles []*stageInput, params map[string]string, nReducers int) ([]string, error) {
+	samples := []string{}
+	for _, file := range inputFiles {
+		keys, err := sampleKeys(job, file, params)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, keys...)
+	}
+	if len(samples) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return job.less(samples[i], samples[j]) })
+	splits := []string{}
+	for i := 1; i < nReducers; i++ {
+		splits = append(splits, samples[i*len(samples)/nReducers])
+	}
+	return splits, nil
+}
+
+// reservoir sample of samplesPerFile keys of the map output of the
+// whole file, so sorted or clustered inputs are sampled evenly. The
+// file is read once from start to end and only the sample is kept
+func sampleKeys(job *Job, file *stageInput, params map[string]string) ([]string, error) {
+	reader, err := file.open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// fixed seed, the same inputs get the same splits
+	random := rand.New(rand.NewSource(1))
+	keys := make([]string, 0, samplesPerFile)
+	pairs := 0
+	emit := func(kvs ...*KeyValue) error {
+		for _, kv := range kvs {
+			pairs++
+			if len(keys) < samplesPerFile {
+				keys = append(keys, kv.Key)
+			} else if i := random.Intn(pairs); i < samplesPerFile {
+				keys[i] = kv.Key
+			}
+		}
+		return nil
+	}
+
+	ctx := newTaskContext(params)
+	if ctx.Param("inputFormat", "whole") != "whole" {
+		_, err = mapRecords(job, file.name, reader, ctx, emit)
+		return keys, err
+	}
+	// every block of lines of the decompressed file is mapped as a whole file
+	decompressed, err := decompressInput(file.name, reader, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer decompressed.Close()
+	blockParams := map[string]string{}
+	for k, v := range params {
+		blockParams[k] = v
+	}
+	blockParams["inputCompression"] = "none"
+	lines := bufio.NewReader(decompressed)
+	buf := make([]byte, sampleBlockBytes)
+	for {
+		n, err := io.ReadFull(lines, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		block := buf[:n]
+		if err == nil {
+			// the block ends at the end of a line
+			rest, restErr := lines.ReadBytes('\n')
+			if restErr != nil && restErr != io.EOF {
+				return nil, restErr
+			}
+			block = append(block, rest...)
+		}
+		if len(block) == 0 {
+			return keys, nil
+		}
+		_, mapErr := mapRecords(job, file.name, bytes.NewReader(block), newTaskContext(blockParams), emit)
+		if mapErr != nil {
+			return nil, mapErr
+		}
+		if err != nil {
+			return keys, nil
+		}
+	}
 }
 
 func InitMasterLogs() error {
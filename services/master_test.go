@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// starts a master, a mapper and a reducer in the test process, in a
+// temporary working directory, and returns a client of the master
+func startTestCluster(t *testing.T) MasterServiceClient {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	oldConfig := MasterConfig
+	oldMaster, oldMapper, oldReducer := masterRootPath, mapperRootPath, reducerRootPath
+	oldMapperPort, oldReducerPort := mapperPort, runningPort
+	t.Cleanup(func() {
+		MasterConfig = oldConfig
+		masterRootPath, mapperRootPath, reducerRootPath = oldMaster, oldMapper, oldReducer
+		mapperPort, runningPort = oldMapperPort, oldReducerPort
+		os.Chdir(dir)
+	})
+
+	listen := func() (net.Listener, string) {
+		listener, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		return listener, port
+	}
+	serve := func(listener net.Listener, register func(s *grpc.Server)) {
+		server := grpc.NewServer()
+		register(server)
+		go server.Serve(listener)
+		t.Cleanup(server.Stop)
+	}
+
+	masterListener, _ := listen()
+	mapperListener, mapperPort := listen()
+	reducerListener, reducerPort := listen()
+	MasterConfig = Config{}
+	MasterConfig.Client.NMappers = 1
+	MasterConfig.Client.NReducers = 1
+	MasterConfig.Mappers.Ports = []string{mapperPort}
+	MasterConfig.Reducers.Ports = []string{reducerPort}
+	for _, err := range []error{InitMasterFileSystem(), InitMapperFileSystem(mapperPort), InitReducerFileSystem(reducerPort)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	serve(masterListener, func(s *grpc.Server) { RegisterMasterServiceServer(s, &MasterServer{}) })
+	serve(mapperListener, func(s *grpc.Server) { RegisterMapperServiceServer(s, &MapperServer{}) })
+	serve(reducerListener, func(s *grpc.Server) { RegisterReducerServiceServer(s, &ReducerServer{}) })
+
+	conn, err := grpc.Dial(masterListener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewMasterServiceClient(conn)
+}
+
+// runs the job on the input files and returns the files of ./output by name
+func runTestJob(t *testing.T, mc MasterServiceClient, input *RunMapRdInput) map[string]string {
+	stream, err := mc.RunMapRd(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		t.Fatalf("running the job: %v", err)
+	}
+	names, err := filepath.Glob("output/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := map[string]string{}
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs[filepath.Base(name)] = string(data)
+	}
+	return outputs
+}
+
+// the reducer outputs of the final stages are kept on the master until
+// the last stage ran, stages of the same function must not share files
+func TestPipelineFinalStagesOfOneFunction(t *testing.T) {
+	mc := startTestCluster(t)
+	if err := os.MkdirAll("input", 0755); err != nil {
+		t.Fatal(err)
+	}
+	text := "a telegram came\nGregory read it\nnothing else\n"
+	if err := os.WriteFile("input/letter.txt", []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputDir, err := filepath.Abs("input")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reducerPort := MasterConfig.Reducers.Ports[0]
+	outputs := runTestJob(t, mc, &RunMapRdInput{
+		Fn: "greps.json",
+		Pipeline: &Pipeline{Stages: []*Stage{
+			{Name: "tele", Fn: "grep", Params: map[string]string{"pattern": "telegram"}},
+			{Name: "gregor", Fn: "grep", Params: map[string]string{"pattern": "Gregory"}},
+		}},
+		Inputs: []string{inputDir},
+	})
+	for stage, want := range map[string]string{"tele": "a telegram came", "gregor": "Gregory read it"} {
+		output, ok := outputs[stage+"_out"+reducerPort+".txt"]
+		if !ok {
+			t.Fatalf("no output of stage %s in %v", stage, outputs)
+		}
+		if !strings.Contains(output, want) || strings.Count(output, "\n") != 1 {
+			t.Errorf("stage %s output %q, want the line %q", stage, output, want)
+		}
+	}
+
+	// merged outputs are formatted by the master
+	outputs = runTestJob(t, mc, &RunMapRdInput{
+		Fn:     "topks.json",
+		Params: map[string]string{"outputFormat": "tsv"},
+		Pipeline: &Pipeline{Stages: []*Stage{
+			{Name: "top1", Fn: "topk", Params: map[string]string{"k": "1"}},
+			{Name: "top3", Fn: "topk", Params: map[string]string{"k": "3"}},
+		}},
+		Inputs: []string{inputDir},
+	})
+	for stage, lines := range map[string]int{"top1": 1, "top3": 3} {
+		output, ok := outputs[stage+"_topk.tsv"]
+		if !ok {
+			t.Fatalf("no output of stage %s in %v", stage, outputs)
+		}
+		if strings.Count(output, "\n") != lines {
+			t.Errorf("stage %s output %q, want %d lines", stage, output, lines)
+		}
+	}
+}
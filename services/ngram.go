@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// n-gram and co-occurrence counts using the pairs pattern,
+// every n-gram or word pair is emitted as a key with the count 1
+// and counted with wcReduce (also the combiner)
+
+const (
+	defaultNgramSize     = 2
+	defaultCooccurWindow = 2
+)
+
+func splitWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) })
+}
+
+// parameters: n (default 2)
+// emits "word1 word2 ... wordN": 1
+func ngramMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	n, err := ctx.IntParam("n", defaultNgramSize)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be positive: %d", n)
+	}
+
+	words := splitWords(value)
+	kvPairs := &KvPairs{}
+	for i := 0; i+n <= len(words); i++ {
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: strings.Join(words[i:i+n], " "), Typed: IntValue(1)})
+	}
+
+	return kvPairs, nil
+}
+
+// parameters: window (default 2)
+// emits "word neighbour": 1 for every neighbour at most
+// window words before or after the word
+func cooccurMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	window, err := ctx.IntParam("window", defaultCooccurWindow)
+	if err != nil {
+		return nil, err
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("window must be positive: %d", window)
+	}
+
+	words := splitWords(value)
+	kvPairs := &KvPairs{}
+	for i, word := range words {
+		for j := i - window; j <= i+window; j++ {
+			if j < 0 || j == i || j >= len(words) {
+				continue
+			}
+			kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: word + " " + words[j], Typed: IntValue(1)})
+		}
+	}
+
+	return kvPairs, nil
+}
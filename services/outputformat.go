@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// output formats of the reducer output files, chosen with the
+// outputFormat job parameter:
+//   - text (default): a "key: value" line per pair, the separator
+//     is set with outputSep
+//   - tsv: a "key\tvalue" line per pair, tabs, line breaks and
+//     backslashes are escaped as \t, \n, \r and \\
+//   - csv: a "key,value" header row and a row per pair
+//   - jsonl: a {"key":...,"value":...} line per pair, typed int
+//     and real values written by the reducers are json numbers
+//   - proto: length delimited KeyValue records, like the intermediate files
+// outputCompression (gzip/zstd/snappy) compresses the output files,
+// their extension tells the format and the compression (ex: out35473.jsonl.gz).
+// Only the outputs of the final stages are formatted, the other
+// stages and the iterations of iterative stages write text read by
+// the next one, and the outputs of jobs with a merge step are
+// formatted by the master after the merge
+type OutputFormat interface {
+	// extension of the output files
+	Ext() string
+	NewWriter(w io.Writer, ctx *TaskContext) (RecordWriter, error)
+}
+
+type RecordWriter interface {
+	Write(kv *KeyValue) error
+	// writes the buffered records, the underlying writer is not closed
+	Flush() error
+}
+
+var outputFormats = map[string]OutputFormat{
+	"text":  textOutputFormat{},
+	"tsv":   tsvOutputFormat{},
+	"csv":   csvOutputFormat{},
+	"jsonl": jsonLinesOutputFormat{},
+	"proto": protoOutputFormat{},
+}
+
+// parameters changing the output files
+var outputParams = []string{"outputFormat", "outputSep", "outputCompression"}
+
+func lookupOutputFormat(name string) (OutputFormat, error) {
+	if len(name) == 0 {
+		name = "text"
+	}
+	format, ok := outputFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q (text/tsv/csv/jsonl/proto)", name)
+	}
+	return format, nil
+}
+
+// extension of the output files of the job, ex: .jsonl.gz
+func outputExt(params map[string]string) (string, error) {
+	format, err := lookupOutputFormat(params["outputFormat"])
+	if err != nil {
+		return "", err
+	}
+	codec, err := lookupCodec(params["outputCompression"])
+	if err != nil {
+		return "", err
+	}
+	return format.Ext() + codec.ext, nil
+}
+
+// parameters of the stages writing text outputs for the master
+// or the next stage, without the output parameters
+func textOutputParams(params map[string]string) map[string]string {
+	text := map[string]string{}
+	for k, v := range params {
+		text[k] = v
+	}
+	for _, name := range outputParams {
+		delete(text, name)
+	}
+	return text
+}
+
+// output file writing formatted records through the compression codec
+type outputWriter struct {
+	records RecordWriter
+	comp    io.WriteCloser
+}
+
+func newOutputWriter(w io.Writer, ctx *TaskContext) (*outputWriter, error) {
+	format, err := lookupOutputFormat(ctx.Param("outputFormat", "text"))
+	if err != nil {
+		return nil, err
+	}
+	codec, err := lookupCodec(ctx.Param("outputCompression", ""))
+	if err != nil {
+		return nil, err
+	}
+	comp, err := codec.newWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	records, err := format.NewWriter(comp, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &outputWriter{records: records, comp: comp}, nil
+}
+
+func (w *outputWriter) Write(kv *KeyValue) error {
+	return w.records.Write(kv)
+}
+
+// flushes the records and the codec, the underlying writer is not closed
+func (w *outputWriter) Close() error {
+	err := w.records.Flush()
+	if closeErr := w.comp.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// formats an output in text returned by the reducers (or a merge
+// step) of the stage with the output parameters into a file of the
+// master, unchanged for text outputs
+func formatOutput(file *FileOutput, params map[string]string, stage string) (*FileOutput, error) {
+	ext, err := outputExt(params)
+	if err != nil {
+		return nil, err
+	}
+	sep, ok := params["outputSep"]
+	if ext == ".txt" && (!ok || sep == ": ") {
+		return file, nil
+	}
+	reader, err := openOutput(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	name := strings.TrimSuffix(file.Name, ".txt") + ext
+	filePath := masterRootPath + "/formatted_" + stage + "_" + name
+	formatted, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer formatted.Close()
+	writer, err := newOutputWriter(formatted, newTaskContext(params))
+	if err != nil {
+		return nil, err
+	}
+	err = readLines(reader, func(offset int64, line string) error {
+		if kv, ok := parseOutputLine(line); ok {
+			return writer.Write(kv)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if err := formatted.Close(); err != nil {
+		return nil, err
+	}
+	return &FileOutput{Name: name, Uri: filePath, Counters: file.Counters}, nil
+}
+
+type textOutputFormat struct{}
+
+func (textOutputFormat) Ext() string { return ".txt" }
+
+func (textOutputFormat) NewWriter(w io.Writer, ctx *TaskContext) (RecordWriter, error) {
+	return &textWriter{w: bufio.NewWriter(w), sep: ctx.Param("outputSep", ": ")}, nil
+}
+
+type textWriter struct {
+	w   *bufio.Writer
+	sep string
+}
+
+func (tw *textWriter) Write(kv *KeyValue) error {
+	_, err := tw.w.WriteString(kv.Key + tw.sep + kv.Value + "\n")
+	return err
+}
+
+func (tw *textWriter) Flush() error {
+	return tw.w.Flush()
+}
+
+type tsvOutputFormat struct{}
+
+func (tsvOutputFormat) Ext() string { return ".tsv" }
+
+func (tsvOutputFormat) NewWriter(w io.Writer, ctx *TaskContext) (RecordWriter, error) {
+	return tsvWriter{&textWriter{w: bufio.NewWriter(w), sep: "\t"}}, nil
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+type tsvWriter struct {
+	*textWriter
+}
+
+func (tw tsvWriter) Write(kv *KeyValue) error {
+	return tw.textWriter.Write(&KeyValue{Key: tsvEscaper.Replace(kv.Key), Value: tsvEscaper.Replace(kv.Value)})
+}
+
+type csvOutputFormat struct{}
+
+func (csvOutputFormat) Ext() string { return ".csv" }
+
+func (csvOutputFormat) NewWriter(w io.Writer, ctx *TaskContext) (RecordWriter, error) {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"key", "value"})
+	if err != nil {
+		return nil, err
+	}
+	return csvWriter{writer}, nil
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw csvWriter) Write(kv *KeyValue) error {
+	return cw.w.Write([]string{kv.Key, kv.Value})
+}
+
+func (cw csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonLinesOutputFormat struct{}
+
+func (jsonLinesOutputFormat) Ext() string { return ".jsonl" }
+
+func (jsonLinesOutputFormat) NewWriter(w io.Writer, ctx *TaskContext) (RecordWriter, error) {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+	return &jsonLinesWriter{w: buffered, encoder: encoder}, nil
+}
+
+type jsonLinesWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+type jsonRecord struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+func (jw *jsonLinesWriter) Write(kv *KeyValue) error {
+	record := jsonRecord{Key: kv.Key, Value: kv.Value}
+	switch v := kv.Typed.GetKind().(type) {
+	case *Value_Int:
+		record.Value = v.Int
+	case *Value_Real:
+		// NaN and infinities are not json numbers
+		if !math.IsNaN(v.Real) && !math.IsInf(v.Real, 0) {
+			record.Value = v.Real
+		}
+	}
+	return jw.encoder.Encode(record)
+}
+
+func (jw *jsonLinesWriter) Flush() error {
+	return jw.w.Flush()
+}
+
+type protoOutputFormat struct{}
+
+func (protoOutputFormat) Ext() string { return ".pb" }
+
+func (protoOutputFormat) NewWriter(w io.Writer, ctx *TaskContext) (RecordWriter, error) {
+	return newRecordWriter(w), nil
+}
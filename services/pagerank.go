@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pagerank over an edge list ("source destination" per line, # comments)
+// parameters: damping (default 0.85), epsilon (default 0.0001)
+// pagerank_init: builds the adjacency lists, "node: 1.000000 out1,out2"
+// pagerank_iter: one iteration, rank = (1 - damping) + damping * sum of
+// rank/outDegree of the incoming nodes, run until no rank changes by
+// more than epsilon
+// emits "node: rank out1,out2"
+
+const (
+	defaultDamping  = 0.85
+	defaultEpsilon  = 0.0001
+	pagerankCounter = "unconverged"
+)
+
+func pagerankInitMap(_key, value string, _ *TaskContext) (*KvPairs, error) {
+	kvPairs := &KvPairs{}
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid edge: %s", line)
+		}
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: fields[0], Value: fields[1]})
+		// nodes without outgoing edges are part of the graph too
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: fields[1], Value: ""})
+	}
+
+	return kvPairs, nil
+}
+
+// values are the destinations of the outgoing edges
+func pagerankInitReduce(_key string, values []string, _ *TaskContext) string {
+	sort.Strings(values)
+	outs := []string{}
+	for i, value := range values {
+		if len(value) > 0 && (i == 0 || values[i-1] != value) {
+			outs = append(outs, value)
+		}
+	}
+	return formatPagerank(1, outs)
+}
+
+func formatPagerank(rank float64, outs []string) string {
+	return fmt.Sprintf("%.6f %s", rank, strings.Join(outs, ","))
+}
+
+// parses "rank out1,out2"
+func parsePagerank(value string) (float64, []string, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0, nil, fmt.Errorf("invalid pagerank record: %s", value)
+	}
+	rank, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid rank: %s", fields[0])
+	}
+	outs := []string{}
+	if len(fields) > 1 {
+		outs = strings.Split(fields[1], ",")
+	}
+	return rank, outs, nil
+}
+
+// value is a reducer output of the previous iteration, emits
+// node: "#out1,out2" to keep the graph, node: "=rank" for the
+// convergence check and out: "contribution" for every outgoing edge
+func pagerankIterMap(_key, value string, _ *TaskContext) (*KvPairs, error) {
+	kvPairs := &KvPairs{}
+	for _, kv := range parseOutputLines(value) {
+		rank, outs, err := parsePagerank(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: kv.Key, Value: "#" + strings.Join(outs, ",")})
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: kv.Key, Value: "=" + strconv.FormatFloat(rank, 'g', -1, 64)})
+		for _, out := range outs {
+			contribution := rank / float64(len(outs))
+			kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: out, Value: strconv.FormatFloat(contribution, 'g', -1, 64)})
+		}
+	}
+
+	return kvPairs, nil
+}
+
+func pagerankIterReduce(key string, values []string, ctx *TaskContext) string {
+	damping, err := ctx.FloatParam("damping", defaultDamping)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	}
+	epsilon, err := ctx.FloatParam("epsilon", defaultEpsilon)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	}
+
+	outs := []string{}
+	previous, sum := 0.0, 0.0
+	for _, value := range values {
+		if strings.HasPrefix(value, "#") {
+			if len(value) > 1 {
+				outs = strings.Split(value[1:], ",")
+			}
+			continue
+		}
+		if strings.HasPrefix(value, "=") {
+			previous, _ = strconv.ParseFloat(value[1:], 64)
+			continue
+		}
+		contribution, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Printf("Error converting value: %s\n", value)
+			continue
+		}
+		sum += contribution
+	}
+
+	rank := (1 - damping) + damping*sum
+	if math.Abs(rank-previous) > epsilon {
+		ctx.Incr(pagerankCounter, 1)
+	}
+	return formatPagerank(rank, outs)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+)
+
+// built-in jobs run as multiple map reduce stages by the master
+var pipelines = map[string]*Pipeline{
+	"tfidf": {Stages: []*Stage{
+		{Name: "tf", Fn: "tfidf_tf"},
+		{Name: "idf", Fn: "tfidf_idf", Inputs: []string{"tf"}},
+	}},
+	"pagerank": {Stages: []*Stage{
+		{Name: "graph", Fn: "pagerank_init"},
+		{Name: "rank", Fn: "pagerank_iter", Inputs: []string{"graph"}, MaxIterations: 20, ConvergenceCounter: pagerankCounter},
+	}},
+}
+
+// pipeline the master runs for fn, a single stage for
+// functions that are not pipelines
+func lookupPipeline(fn string) (*Pipeline, error) {
+	if pipeline, ok := pipelines[fn]; ok {
+		return pipeline, nil
+	}
+	if _, err := lookupJob(fn); err != nil {
+		return nil, err
+	}
+	return &Pipeline{Stages: []*Stage{{Name: fn, Fn: fn}}}, nil
+}
+
+// validates the pipeline and orders the stages so that every stage
+// runs after the stages it takes inputs from
+func schedulePipeline(pipeline *Pipeline) ([]*Stage, error) {
+	if len(pipeline.Stages) == 0 {
+		return nil, fmt.Errorf("pipeline has no stages")
+	}
+
+	byName := map[string]*Stage{}
+	for _, stage := range pipeline.Stages {
+		if len(stage.Name) == 0 {
+			return nil, fmt.Errorf("pipeline stage without a name")
+		}
+		if _, ok := byName[stage.Name]; ok {
+			return nil, fmt.Errorf("duplicate pipeline stage: %s", stage.Name)
+		}
+		if _, err := lookupJob(stage.Fn); err != nil {
+			return nil, fmt.Errorf("stage %s: %v", stage.Name, err)
+		}
+		if len(stage.ConvergenceCounter) > 0 && stage.MaxIterations <= 0 {
+			return nil, fmt.Errorf("stage %s: convergence counter needs maxIterations", stage.Name)
+		}
+		byName[stage.Name] = stage
+	}
+	for _, stage := range pipeline.Stages {
+		for _, input := range stage.Inputs {
+			if _, ok := byName[input]; !ok {
+				return nil, fmt.Errorf("stage %s: unknown input stage %s", stage.Name, input)
+			}
+		}
+	}
+
+	// depth first topological sort, keeps the given order
+	// for stages that do not depend on each other
+	order := []*Stage{}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(stage *Stage) error
+	visit = func(stage *Stage) error {
+		switch state[stage.Name] {
+		case visiting:
+			return fmt.Errorf("pipeline has a cycle at stage %s", stage.Name)
+		case done:
+			return nil
+		}
+		state[stage.Name] = visiting
+		for _, input := range stage.Inputs {
+			if err := visit(byName[input]); err != nil {
+				return err
+			}
+		}
+		state[stage.Name] = done
+		order = append(order, stage)
+		return nil
+	}
+	for _, stage := range pipeline.Stages {
+		if err := visit(stage); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// stages whose outputs are not the inputs of any other stage
+func finalStages(pipeline *Pipeline) map[string]bool {
+	used := map[string]bool{}
+	for _, stage := range pipeline.Stages {
+		for _, input := range stage.Inputs {
+			used[input] = true
+		}
+	}
+	finals := map[string]bool{}
+	for _, stage := range pipeline.Stages {
+		if !used[stage.Name] {
+			finals[stage.Name] = true
+		}
+	}
+	return finals
+}
+
+// job parameters overridden by the parameters of the stage
+func stageParams(params map[string]string, stage *Stage) map[string]string {
+	merged := map[string]string{}
+	for k, v := range params {
+		merged[k] = v
+	}
+	for k, v := range stage.Params {
+		merged[k] = v
+	}
+	return merged
+}
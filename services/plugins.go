@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"sync"
+)
+
+// jobs that are not in the function registry are loaded from
+// Go plugins, <PluginDir>/<fn>.so built with
+// go build -buildmode=plugin, a plugin exports:
+//
+//	var APIVersion = 1
+//	func Map(key, value string, params map[string]string, emit func(key, value string)) error
+//	func Reduce(key string, values []string, params map[string]string) (string, error)
+//	func Combine(key string, values []string, params map[string]string) (string, error) // optional
+//
+// plugins must be built with the same Go version and module
+// versions as the workers, every worker loads the plugin itself
+
+// version of the plugin symbols above
+const PluginAPIVersion = 1
+
+// directory the workers load plugins from
+var PluginDir = "./plugins"
+
+type pluginMapFn = func(key, value string, params map[string]string, emit func(key, value string)) error
+type pluginReduceFn = func(key string, values []string, params map[string]string) (string, error)
+
+var pluginJobs = map[string]*Job{}
+var pluginMu sync.Mutex
+
+func lookupPluginJob(fn string) (*Job, error) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	if job, ok := pluginJobs[fn]; ok {
+		return job, nil
+	}
+
+	if len(fn) == 0 || strings.ContainsAny(fn, `/\`) || strings.Contains(fn, "..") {
+		return nil, fmt.Errorf("unknown function: %s", fn)
+	}
+	path := filepath.Join(PluginDir, fn+".so")
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("unknown function: %s (no plugin at %s on this worker)", fn, path)
+	}
+
+	log.Printf("Loading plugin %s\n", path)
+	job, err := loadPlugin(path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %v", path, err)
+	}
+	pluginJobs[fn] = job
+	return job, nil
+}
+
+func loadPlugin(path string) (*Job, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := p.Lookup("APIVersion")
+	if err != nil {
+		return nil, fmt.Errorf("missing APIVersion")
+	}
+	versionVal, ok := version.(*int)
+	if !ok {
+		return nil, fmt.Errorf("APIVersion is %T, want int", version)
+	}
+	if *versionVal != PluginAPIVersion {
+		return nil, fmt.Errorf("plugin API version %d, workers support version %d", *versionVal, PluginAPIVersion)
+	}
+
+	mapSym, err := p.Lookup("Map")
+	if err != nil {
+		return nil, fmt.Errorf("missing Map function")
+	}
+	mapFn, ok := mapSym.(pluginMapFn)
+	if !ok {
+		return nil, fmt.Errorf("Map is %T, want %T", mapSym, pluginMapFn(nil))
+	}
+	reduceSym, err := p.Lookup("Reduce")
+	if err != nil {
+		return nil, fmt.Errorf("missing Reduce function")
+	}
+	reduceFn, ok := reduceSym.(pluginReduceFn)
+	if !ok {
+		return nil, fmt.Errorf("Reduce is %T, want %T", reduceSym, pluginReduceFn(nil))
+	}
+
+	job := &Job{
+		Map: func(key, value string, ctx *TaskContext) (*KvPairs, error) {
+			kvPairs := &KvPairs{}
+			err := mapFn(key, value, ctx.Params, func(key, value string) {
+				kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: key, Value: value})
+			})
+			return kvPairs, err
+		},
+		ReduceAll: func(groups Groups, ctx *TaskContext, emit func(kv *KeyValue) error) error {
+			for groups.Next() {
+				out, err := reduceFn(groups.Key(), groupValues(groups), ctx.Params)
+				if err != nil {
+					return err
+				}
+				if err := emit(&KeyValue{Key: groups.Key(), Value: out}); err != nil {
+					return err
+				}
+			}
+			return groups.Err()
+		},
+	}
+
+	if combineSym, err := p.Lookup("Combine"); err == nil {
+		combineFn, ok := combineSym.(pluginReduceFn)
+		if !ok {
+			return nil, fmt.Errorf("Combine is %T, want %T", combineSym, pluginReduceFn(nil))
+		}
+		job.CombineValues = pluginCombiner(combineFn)
+	}
+
+	return job, nil
+}
+
+// typed combiner calling the Combine function of a plugin, on
+// error the mapper sends the pairs of the key uncombined
+func pluginCombiner(combineFn pluginReduceFn) ReduceValuesFn {
+	return func(key string, values []*Value, ctx *TaskContext) (*Value, error) {
+		texts := make([]string, 0, len(values))
+		for _, value := range values {
+			texts = append(texts, formatValue(value))
+		}
+		out, err := combineFn(key, texts, ctx.Params)
+		if err != nil {
+			return nil, err
+		}
+		return StrValue(out), nil
+	}
+}
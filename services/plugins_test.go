@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestPluginCombiner(t *testing.T) {
+	sum := func(key string, values []string, params map[string]string) (string, error) {
+		total := 0
+		for _, value := range values {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return "", err
+			}
+			total += n
+		}
+		return strconv.Itoa(total), nil
+	}
+	job := &Job{CombineValues: pluginCombiner(sum)}
+	ctx := newTaskContext(nil)
+
+	group := []*KeyValue{{Key: "a", Value: "1"}, {Key: "a", Typed: IntValue(2)}, {Key: "a", Value: "3"}}
+	combined := combine(job, "a", group, ctx)
+	if len(combined) != 1 || textOf(combined[0]) != "6" {
+		t.Errorf("combined %v, want a single pair of value 6", combined)
+	}
+
+	// a failing combiner leaves the values to the reducer
+	group = []*KeyValue{{Key: "b", Value: "1"}, {Key: "b", Value: "x"}}
+	combined = combine(job, "b", group, ctx)
+	if len(combined) != len(group) {
+		t.Fatalf("combined %v, want the %d pairs of the group", combined, len(group))
+	}
+	for i, kv := range combined {
+		if kv != group[i] {
+			t.Errorf("pair %d is %v, want %v", i, kv, group[i])
+		}
+	}
+
+	failing := &Job{CombineValues: pluginCombiner(func(string, []string, map[string]string) (string, error) {
+		return "", errors.New("combiner failed")
+	})}
+	if combined := combine(failing, "c", group, ctx); len(combined) != len(group) {
+		t.Errorf("combined %v, want the %d pairs of the group", combined, len(group))
+	}
+}
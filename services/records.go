@@ -0,0 +1,89 @@
+package services
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// intermediate files are streams of length delimited KeyValue
+// records (uvarint size followed by the serialized record), so
+// they are written and read one record at a time
+
+// records of an intermediate file sent in one chunk
+const chunkRecords = 1000
+
+// max bytes sent in one chunk of an intermediate or output file
+const chunkBytes = 1 << 20
+
+type recordWriter struct {
+	w   *bufio.Writer
+	buf []byte
+	// records written
+	n int64
+}
+
+func newRecordWriter(w io.Writer) *recordWriter {
+	return &recordWriter{w: bufio.NewWriter(w)}
+}
+
+func (rw *recordWriter) Write(kv *KeyValue) error {
+	data, err := proto.Marshal(kv)
+	if err != nil {
+		return err
+	}
+	rw.buf = binary.AppendUvarint(rw.buf[:0], uint64(len(data)))
+	if _, err := rw.w.Write(rw.buf); err != nil {
+		return err
+	}
+	_, err = rw.w.Write(data)
+	if err == nil {
+		rw.n++
+	}
+	return err
+}
+
+func (rw *recordWriter) Flush() error {
+	return rw.w.Flush()
+}
+
+type recordReader struct {
+	r   *bufio.Reader
+	buf []byte
+	// records read
+	n int64
+}
+
+func newRecordReader(r io.Reader) *recordReader {
+	return &recordReader{r: bufio.NewReader(r)}
+}
+
+// Next returns io.EOF after the last record
+func (rr *recordReader) Next() (*KeyValue, error) {
+	size, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > chunkBytes*64 {
+		return nil, fmt.Errorf("record of %d bytes is too large", size)
+	}
+	if uint64(cap(rr.buf)) < size {
+		rr.buf = make([]byte, size)
+	}
+	rr.buf = rr.buf[:size]
+	if _, err := io.ReadFull(rr.r, rr.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	kv := &KeyValue{}
+	if err := proto.Unmarshal(rr.buf, kv); err != nil {
+		return nil, err
+	}
+	rr.n++
+	return kv, nil
+}
@@ -1,17 +1,17 @@
 package services
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 
-	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -22,114 +22,248 @@ type ReducerServer struct {
 var reducerRootPath string
 var runningPort string
 
-func (s *ReducerServer) SendIntermediateData(ctx context.Context, input *IntermediateData) (*emptypb.Empty, error) {
-	log.Printf("Recieved intermediate data\n")
-	filePath := reducerRootPath + "/" + input.FileName
-	
-	data, err := proto.Marshal(input.Data)
-	if err != nil {
-		log.Printf("Error serializing intermediate data: %v\n", err)
-		return &emptypb.Empty{}, err
+func (s *ReducerServer) SendIntermediateData(stream ReducerService_SendIntermediateDataServer) error {
+	var writer *recordFileWriter
+	filePath := ""
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the mapper cancels the stream of a corrupt file
+			if writer != nil {
+				writer.Close()
+				os.Remove(filePath)
+			}
+			return err
+		}
+		// file is created on the first chunk
+		if writer == nil {
+			log.Printf("Recieving intermediate data: %s\n", chunk.FileName)
+			codec, err := lookupCodec(chunk.Compression)
+			if err != nil {
+				return err
+			}
+			filePath = reducerRootPath + "/" + filepath.Base(chunk.FileName)
+			writer, err = createRecordFile(filePath, codec)
+			if err != nil {
+				log.Printf("Error creating intermediate file: %v\n", err)
+				return err
+			}
+			defer writer.Close()
+		}
+		for _, kv := range chunk.Data {
+			err = writer.Write(kv)
+			if err != nil {
+				log.Printf("Error writing serialized data: %v\n", err)
+				return err
+			}
+		}
 	}
 
-	err = os.WriteFile(filePath, data, 0644)
-	if err != nil {
-		log.Printf("Error writing serialized data: %v\n", err)
-		return &emptypb.Empty{}, err
+	if writer != nil {
+		err := writer.Close()
+		if err != nil {
+			log.Printf("Error writing serialized data: %v\n", err)
+			return err
+		}
 	}
 
-	return &emptypb.Empty{}, nil
+	return stream.SendAndClose(&emptypb.Empty{})
+}
+
+func (s *ReducerServer) RunReduce(input *RunReduceInput, stream ReducerService_RunReduceServer) error {
+	err := runReduce(input, stream)
+	// the next reduce task only sees its own files, they are kept when
+	// one is corrupt since the master only sends that one again
+	err = corruptStatus(err)
+	if len(corruptFiles(err)) == 0 {
+		for _, partitionFile := range input.Files {
+			os.Remove(reducerRootPath + "/" + filepath.Base(partitionFile.FileName))
+		}
+	}
+	return err
 }
 
-func (s *ReducerServer) RunReduce(ctx context.Context, input *RunReduceInput) (*FileOutput, error) {
+func runReduce(input *RunReduceInput, stream ReducerService_RunReduceServer) error {
 	log.Printf("Starting redue task!\n")
-	// read all intermediate files
-	groupedData := make(map[string][]string)
+	job, err := lookupJob(input.Fn)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return err
+	}
+	taskCtx := newTaskContext(input.Params)
+	codec, err := lookupCodec(taskCtx.Param("compression", ""))
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return err
+	}
 
-	// input files, read how?
-	// same as mapper
-	files, _ := os.ReadDir(reducerRootPath)
-	bufferFiles := []string{}
+	// input files are the partition files of this reducer in the map
+	// manifests, sent by the mappers before the reduce task starts.
+	// After every reduce task is complete file system is cleaned
+	// except for logs
 
-	// after every reduce task is complete
-	// file system is cleaned except for logs
-	for _, file := range files {
-		fileName := file.Name()
-		if strings.Contains(fileName, "bucket") {
-			log.Printf("Loading file %s\n", fileName)
-			bufferFiles = append(bufferFiles, fileName)
+	// every buffer file is sorted by the mapper, a k-way merge
+	// of the files streams the keys in order with their values
+	// grouped, so only the values of one key are held in memory
+	sources := []recordSource{}
+	readers := []*recordFileReader{}
+	for _, partitionFile := range input.Files {
+		fileName := filepath.Base(partitionFile.FileName)
+		log.Printf("Reading buffer file: %s\n", fileName)
+		reader, err := openRecordFile(reducerRootPath + "/" + fileName, codec)
+		if err != nil {
+			log.Printf("Error reading intermediate file: %s\n", fileName)
+			return err
 		}
+		defer reader.Close()
+		sources = append(sources, reader)
+		readers = append(readers, reader)
 	}
+	groups := newGroupIterator(newMergeIterator(sources, job.less))
 
-	var wg sync.WaitGroup
-	kvChan := make(chan *KeyValue, 1000)
+	log.Printf("Merging %d sorted buffer files\n", len(sources))
+	ext, err := outputExt(input.Params)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return err
+	}
+	outFileName := fmt.Sprintf("out%s%s", runningPort, ext)
+	log.Printf("Writing result to %s file...on %s\n", outFileName, runningPort)
+	outFilePath := fmt.Sprintf("%s/%s", reducerRootPath, outFileName)
+	file, err := os.OpenFile(outFilePath, os.O_RDWR | os.O_CREATE | os.O_TRUNC, 0666)
+	if err != nil {
+		log.Printf("Error creating output file: %v\n", err)
+		return err
+	}
+	defer file.Close()
+	out, err := newOutputWriter(file, taskCtx)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return err
+	}
 
-	for _, fileName := range bufferFiles {
-		// multiple threads are spawned to
-		// read files and sends data to the kvChannel
-		wg.Add(1)
-		go func(fileName string) {
-			defer wg.Done()
+	// output is written in key order as the keys are reduced
+	emit := out.Write
+	if job.ReduceAll != nil {
+		err = job.ReduceAll(groups, taskCtx, emit)
+	} else {
+		err = reduceGroups(job, groups, taskCtx, emit)
+	}
+	if err != nil {
+		log.Printf("Error running reduce function: %v\n", err)
+		return err
+	}
+	log.Printf("Reduce operation complete!\n")
 
-			log.Printf("Reading buffer file: %s\n", fileName)
-			fileData, err := os.ReadFile(reducerRootPath + "/" + fileName)
-			if err != nil {
-				log.Printf("Error reading intermediate file: %s\n", fileName)
-				return
-			}
+	// every record of the manifest was received, records left
+	// by a reduce function stopping early are read first
+	for groups.Next() {
+	}
+	if err := groups.Err(); err != nil {
+		log.Printf("Error reading intermediate files: %v\n", err)
+		return err
+	}
+	for i, reader := range readers {
+		if err := reader.Verify(input.Files[i].Records, input.Files[i].Checksum); err != nil {
+			log.Printf("Error: %v\n", err)
+			return err
+		}
+	}
 
-			log.Printf("Deserializing buffer file: %s\n", fileName)
-			data := &KvPairs{}
-			err = proto.Unmarshal(fileData, data)
-			if err != nil {
-				log.Printf("Error deserializing the data: %v\n", err)
-				return
-			}
-			log.Printf("Streaming kv pairs to grouping thread!\n")
-			for _, kv := range data.Data {
-				kvChan <- kv
-			}
-		}(fileName)
-	}
-
-	// a go routine that listens on the kvChan to group data
-	log.Printf("Registering a thread to group data\n")
-	go func() {
-		for kv := range kvChan {
-			_, ok := groupedData[kv.Key]
-			if ok {
-				// key present! append data
-				groupedData[kv.Key] = append(groupedData[kv.Key], kv.Value)
-			} else {
-				groupedData[kv.Key] = []string{kv.Value}
-			}
+	if codec.name != "none" {
+		// bytes saved by compressing the intermediate files,
+		// summed by the master over all the reducers
+		var rawBytes, compressedBytes int64
+		for _, reader := range readers {
+			raw, compressed := reader.Stats()
+			rawBytes += raw
+			compressedBytes += compressed
 		}
-	}()
+		taskCtx.Incr(intermediateBytesCounter, rawBytes)
+		taskCtx.Incr(compressedBytesCounter, compressedBytes)
+		log.Printf("Intermediate files: %d bytes, %d bytes with %s compression (%.1f%% saved)\n",
+			rawBytes, compressedBytes, codec.name, savedPercent(rawBytes, compressedBytes))
+	}
 
-	wg.Wait()
-	close(kvChan)
+	err = out.Close()
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		log.Printf("Error writing output: %v\n", err)
+		return err
+	}
 
-	log.Printf("Groupby operation complete!\n")
-	log.Printf("Writing result to out.txt file...on %s\n", runningPort)
-	outFileName := fmt.Sprintf("out%s.txt", runningPort)
-	outFilePath := fmt.Sprintf("%s/%s", reducerRootPath, outFileName)
-	file, _ := os.OpenFile(outFilePath, os.O_RDWR | os.O_CREATE | os.O_APPEND, 0666)
-	
-	var out string
-	for k, v := range groupedData {
-		if input.Fn == "wc" {
-			out = wcReduce(k, v)
-		} else {
-			out = invIndexReduce(k, v)
-		}
-		_, err := file.WriteString(fmt.Sprintf("%s: %s\n", k, out))
+	// output written to storage, the master only gets the counters
+	if len(input.OutputUri) > 0 {
+		log.Printf("Writing output file to %s\n", input.OutputUri)
+		err = copyToURI(outFilePath, input.OutputUri)
 		if err != nil {
 			log.Printf("Error writing output: %v\n", err)
+			return err
 		}
+		return stream.Send(&FileOutput{Name: outFileName, Uri: input.OutputUri, Counters: taskCtx.Counters})
 	}
+	return sendOutputFile(stream, outFilePath, outFileName, taskCtx.Counters)
+}
 
-	bytes, _ := os.ReadFile(outFilePath)
-	return &FileOutput{Name: outFileName, Data: bytes}, nil
+// calls the reduce function of the job on every key
+func reduceGroups(job *Job, groups Groups, ctx *TaskContext, emit func(kv *KeyValue) error) error {
+	for groups.Next() {
+		key := groups.Key()
+		kv := &KeyValue{Key: key}
+		if job.ReduceValues != nil {
+			values := make([]*Value, 0, len(groups.Values()))
+			for _, v := range groups.Values() {
+				values = append(values, valueOf(v))
+			}
+			value, err := job.ReduceValues(key, values, ctx)
+			if err != nil {
+				return fmt.Errorf("reducing key %s: %v", key, err)
+			}
+			kv.Value, kv.Typed = formatValue(value), value
+		} else {
+			kv.Value = job.Reduce(key, groupValues(groups), ctx)
+		}
+		if err := emit(kv); err != nil {
+			return err
+		}
+	}
+	return groups.Err()
+}
+
+// streams the output file in chunks of chunkBytes
+func sendOutputFile(stream ReducerService_RunReduceServer, filePath, name string, counters map[string]int64) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	checksum := crc32.New(crc32cTable)
+	buf := make([]byte, chunkBytes)
+	for {
+		n, err := io.ReadFull(file, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := n < len(buf)
+		checksum.Write(buf[:n])
+		chunk := &FileOutput{Name: name, Data: buf[:n]}
+		if last {
+			chunk.Counters = counters
+			chunk.Checksum = checksum.Sum32()
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
 }
 
 func InitReducerLogs() error {
@@ -156,20 +290,11 @@ func InitReducerFileSystem(port string) (error) {
 	return nil
 }
 
-func wcReduce(key string, values []string) string {
-	sum := 0
-	for i := 0; i < len(values); i++ {
-		intVal, err := strconv.Atoi(values[i])
-		if err != nil {
-			log.Printf("Error converting value: %s\n", values[i])
-		}
-		sum += intVal
-	}
-
-	return strconv.Itoa(sum)
+func wcReduce(key string, values []*Value, _ *TaskContext) (*Value, error) {
+	return sumValues(values)
 }
 
-func invIndexReduce(key string, values []string) string {
+func invIndexReduce(key string, values []string, _ *TaskContext) string {
 	// sort the strings to make it easier to generate
 	// unique file names
 	sort.Strings(values)
@@ -177,10 +302,65 @@ func invIndexReduce(key string, values []string) string {
 	lastOut := ""
 	for _, value := range values {
 		if lastOut != value {
-			// removing "input_" from file name
-			out = append(out, value[6:])
+			out = append(out, value)
 			lastOut = value
 		}
 	}
 	return fmt.Sprintf("%d %s", len(out), strings.Join(out, ","))
+}
+
+// posting of a single document in the positional inverted index
+type posting struct {
+	Doc string `json:"doc"`
+	Tf int `json:"tf"`
+	Positions [][2]int `json:"positions"`
+}
+
+// postings list of a word, df is the number of documents containing it
+type postingsList struct {
+	Df int `json:"df"`
+	Postings []*posting `json:"postings"`
+}
+
+// values are "line:offset:fileName" as emitted by invIndexPosMap
+func invIndexPosReduce(key string, values []string, _ *TaskContext) string {
+	postings := map[string]*posting{}
+	for _, value := range values {
+		parts := strings.SplitN(value, ":", 3)
+		if len(parts) != 3 {
+			log.Printf("Error parsing position: %s\n", value)
+			continue
+		}
+		line, lineErr := strconv.Atoi(parts[0])
+		offset, offsetErr := strconv.Atoi(parts[1])
+		if lineErr != nil || offsetErr != nil {
+			log.Printf("Error parsing position: %s\n", value)
+			continue
+		}
+		p, ok := postings[parts[2]]
+		if !ok {
+			p = &posting{Doc: parts[2]}
+			postings[parts[2]] = p
+		}
+		p.Tf++
+		p.Positions = append(p.Positions, [2]int{line, offset})
+	}
+
+	list := &postingsList{Df: len(postings)}
+	for _, p := range postings {
+		sort.Slice(p.Positions, func(i, j int) bool {
+			if p.Positions[i][0] != p.Positions[j][0] {
+				return p.Positions[i][0] < p.Positions[j][0]
+			}
+			return p.Positions[i][1] < p.Positions[j][1]
+		})
+		list.Postings = append(list.Postings, p)
+	}
+	sort.Slice(list.Postings, func(i, j int) bool { return list.Postings[i].Doc < list.Postings[j].Doc })
+
+	out, err := json.Marshal(list)
+	if err != nil {
+		log.Printf("Error encoding postings list: %v\n", err)
+	}
+	return string(out)
 }
\ No newline at end of file
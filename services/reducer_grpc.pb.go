@@ -23,8 +23,8 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ReducerServiceClient interface {
-	SendIntermediateData(ctx context.Context, in *IntermediateData, opts ...grpc.CallOption) (*emptypb.Empty, error)
-	RunReduce(ctx context.Context, in *RunReduceInput, opts ...grpc.CallOption) (*FileOutput, error)
+	SendIntermediateData(ctx context.Context, opts ...grpc.CallOption) (ReducerService_SendIntermediateDataClient, error)
+	RunReduce(ctx context.Context, in *RunReduceInput, opts ...grpc.CallOption) (ReducerService_RunReduceClient, error)
 }
 
 type reducerServiceClient struct {
@@ -35,30 +35,78 @@ func NewReducerServiceClient(cc grpc.ClientConnInterface) ReducerServiceClient {
 	return &reducerServiceClient{cc}
 }
 
-func (c *reducerServiceClient) SendIntermediateData(ctx context.Context, in *IntermediateData, opts ...grpc.CallOption) (*emptypb.Empty, error) {
-	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, "/services.ReducerService/SendIntermediateData", in, out, opts...)
+func (c *reducerServiceClient) SendIntermediateData(ctx context.Context, opts ...grpc.CallOption) (ReducerService_SendIntermediateDataClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ReducerService_ServiceDesc.Streams[0], "/services.ReducerService/SendIntermediateData", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &reducerServiceSendIntermediateDataClient{stream}
+	return x, nil
 }
 
-func (c *reducerServiceClient) RunReduce(ctx context.Context, in *RunReduceInput, opts ...grpc.CallOption) (*FileOutput, error) {
-	out := new(FileOutput)
-	err := c.cc.Invoke(ctx, "/services.ReducerService/RunReduce", in, out, opts...)
+type ReducerService_SendIntermediateDataClient interface {
+	Send(*IntermediateChunk) error
+	CloseAndRecv() (*emptypb.Empty, error)
+	grpc.ClientStream
+}
+
+type reducerServiceSendIntermediateDataClient struct {
+	grpc.ClientStream
+}
+
+func (x *reducerServiceSendIntermediateDataClient) Send(m *IntermediateChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *reducerServiceSendIntermediateDataClient) CloseAndRecv() (*emptypb.Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(emptypb.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *reducerServiceClient) RunReduce(ctx context.Context, in *RunReduceInput, opts ...grpc.CallOption) (ReducerService_RunReduceClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ReducerService_ServiceDesc.Streams[1], "/services.ReducerService/RunReduce", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &reducerServiceRunReduceClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ReducerService_RunReduceClient interface {
+	Recv() (*FileOutput, error)
+	grpc.ClientStream
+}
+
+type reducerServiceRunReduceClient struct {
+	grpc.ClientStream
+}
+
+func (x *reducerServiceRunReduceClient) Recv() (*FileOutput, error) {
+	m := new(FileOutput)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
 // ReducerServiceServer is the server API for ReducerService service.
 // All implementations must embed UnimplementedReducerServiceServer
 // for forward compatibility
 type ReducerServiceServer interface {
-	SendIntermediateData(context.Context, *IntermediateData) (*emptypb.Empty, error)
-	RunReduce(context.Context, *RunReduceInput) (*FileOutput, error)
+	SendIntermediateData(ReducerService_SendIntermediateDataServer) error
+	RunReduce(*RunReduceInput, ReducerService_RunReduceServer) error
 	mustEmbedUnimplementedReducerServiceServer()
 }
 
@@ -66,11 +114,11 @@ type ReducerServiceServer interface {
 type UnimplementedReducerServiceServer struct {
 }
 
-func (UnimplementedReducerServiceServer) SendIntermediateData(context.Context, *IntermediateData) (*emptypb.Empty, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method SendIntermediateData not implemented")
+func (UnimplementedReducerServiceServer) SendIntermediateData(ReducerService_SendIntermediateDataServer) error {
+	return status.Errorf(codes.Unimplemented, "method SendIntermediateData not implemented")
 }
-func (UnimplementedReducerServiceServer) RunReduce(context.Context, *RunReduceInput) (*FileOutput, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method RunReduce not implemented")
+func (UnimplementedReducerServiceServer) RunReduce(*RunReduceInput, ReducerService_RunReduceServer) error {
+	return status.Errorf(codes.Unimplemented, "method RunReduce not implemented")
 }
 func (UnimplementedReducerServiceServer) mustEmbedUnimplementedReducerServiceServer() {}
 
@@ -85,40 +133,51 @@ func RegisterReducerServiceServer(s grpc.ServiceRegistrar, srv ReducerServiceSer
 	s.RegisterService(&ReducerService_ServiceDesc, srv)
 }
 
-func _ReducerService_SendIntermediateData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(IntermediateData)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(ReducerServiceServer).SendIntermediateData(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/services.ReducerService/SendIntermediateData",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ReducerServiceServer).SendIntermediateData(ctx, req.(*IntermediateData))
-	}
-	return interceptor(ctx, in, info, handler)
+func _ReducerService_SendIntermediateData_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ReducerServiceServer).SendIntermediateData(&reducerServiceSendIntermediateDataServer{stream})
+}
+
+type ReducerService_SendIntermediateDataServer interface {
+	SendAndClose(*emptypb.Empty) error
+	Recv() (*IntermediateChunk, error)
+	grpc.ServerStream
+}
+
+type reducerServiceSendIntermediateDataServer struct {
+	grpc.ServerStream
+}
+
+func (x *reducerServiceSendIntermediateDataServer) SendAndClose(m *emptypb.Empty) error {
+	return x.ServerStream.SendMsg(m)
 }
 
-func _ReducerService_RunReduce_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RunReduceInput)
-	if err := dec(in); err != nil {
+func (x *reducerServiceSendIntermediateDataServer) Recv() (*IntermediateChunk, error) {
+	m := new(IntermediateChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(ReducerServiceServer).RunReduce(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/services.ReducerService/RunReduce",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ReducerServiceServer).RunReduce(ctx, req.(*RunReduceInput))
+	return m, nil
+}
+
+func _ReducerService_RunReduce_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunReduceInput)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
 	}
-	return interceptor(ctx, in, info, handler)
+	return srv.(ReducerServiceServer).RunReduce(m, &reducerServiceRunReduceServer{stream})
+}
+
+type ReducerService_RunReduceServer interface {
+	Send(*FileOutput) error
+	grpc.ServerStream
+}
+
+type reducerServiceRunReduceServer struct {
+	grpc.ServerStream
+}
+
+func (x *reducerServiceRunReduceServer) Send(m *FileOutput) error {
+	return x.ServerStream.SendMsg(m)
 }
 
 // ReducerService_ServiceDesc is the grpc.ServiceDesc for ReducerService service.
@@ -127,16 +186,18 @@ func _ReducerService_RunReduce_Handler(srv interface{}, ctx context.Context, dec
 var ReducerService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "services.ReducerService",
 	HandlerType: (*ReducerServiceServer)(nil),
-	Methods: []grpc.MethodDesc{
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
 		{
-			MethodName: "SendIntermediateData",
-			Handler:    _ReducerService_SendIntermediateData_Handler,
+			StreamName:    "SendIntermediateData",
+			Handler:       _ReducerService_SendIntermediateData_Handler,
+			ClientStreams: true,
 		},
 		{
-			MethodName: "RunReduce",
-			Handler:    _ReducerService_RunReduce_Handler,
+			StreamName:    "RunReduce",
+			Handler:       _ReducerService_RunReduce_Handler,
+			ServerStreams: true,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "services/reducer.proto",
 }
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// script jobs are ad-hoc jobs described by the job parameters
+// parameters:
+//   map: a source followed by operations, separated by " | "
+//     sources: lines, words (records are the non empty lines or the words)
+//     lower, upper, trim: transform the record
+//     match <regex>, skip <regex>: keep or drop the matching records
+//     split <sep>: separator of the fields $1..$n (default whitespace)
+//     key <template>, value <template>: emitted pair (default $0 and 1)
+//     templates replace $0 (record), $1..$n (fields), $file and $line
+//   reduce: sum, count, min, max, distinct or concat, keys without
+//     numeric values are left out of the sum, min and max outputs
+// ex: map="lines | skip ^# | split , | key $2 | value $4" reduce=sum
+
+type scriptOp struct {
+	name string
+	arg  string
+	re   *regexp.Regexp
+}
+
+type mapScript struct {
+	source string
+	ops    []*scriptOp
+	sep    string
+	key    string
+	value  string
+}
+
+var scriptAggregators = map[string]bool{
+	"sum": true, "count": true, "min": true, "max": true, "distinct": true, "concat": true,
+}
+
+func parseMapScript(script string) (*mapScript, error) {
+	parts := strings.Split(script, " | ")
+	ms := &mapScript{source: strings.TrimSpace(parts[0]), key: "$0", value: "1"}
+	if ms.source != "lines" && ms.source != "words" {
+		return nil, fmt.Errorf("script source must be lines or words: %q", ms.source)
+	}
+
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		name, arg, _ := strings.Cut(part, " ")
+		op := &scriptOp{name: name, arg: arg}
+		switch name {
+		case "lower", "upper", "trim":
+		case "match", "skip":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("script %s: %v", name, err)
+			}
+			op.re = re
+		case "split":
+			ms.sep = strings.ReplaceAll(arg, `\t`, "\t")
+			continue
+		case "key", "value":
+			if err := checkTemplate(arg); err != nil {
+				return nil, fmt.Errorf("script %s: %v", name, err)
+			}
+			if name == "key" {
+				ms.key = arg
+			} else {
+				ms.value = arg
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("unknown script operation: %q", part)
+		}
+		ms.ops = append(ms.ops, op)
+	}
+
+	return ms, nil
+}
+
+var templateVar = regexp.MustCompile(`\$(\d+|file|line)`)
+
+// fields are numbered from 1, $0 is the record
+func checkTemplate(template string) error {
+	for _, match := range templateVar.FindAllStringSubmatch(template, -1) {
+		if match[1] == "0" || match[1] == "file" || match[1] == "line" {
+			continue
+		}
+		if i, err := strconv.Atoi(match[1]); err != nil || i < 1 {
+			return fmt.Errorf("invalid field %s in %q", match[0], template)
+		}
+	}
+	return nil
+}
+
+func expandTemplate(template, record, sep, file string, line int) string {
+	var fields []string
+	return templateVar.ReplaceAllStringFunc(template, func(v string) string {
+		switch v[1:] {
+		case "file":
+			return file
+		case "line":
+			return strconv.Itoa(line)
+		case "0":
+			return record
+		}
+		if fields == nil {
+			if len(sep) == 0 {
+				fields = strings.Fields(record)
+			} else {
+				fields = strings.Split(record, sep)
+			}
+		}
+		i, err := strconv.Atoi(v[1:])
+		if err != nil || i < 1 || i > len(fields) {
+			return ""
+		}
+		return fields[i-1]
+	})
+}
+
+// runs the operations on a record, false when it is dropped
+func (ms *mapScript) apply(record string) (string, bool) {
+	for _, op := range ms.ops {
+		switch op.name {
+		case "lower":
+			record = strings.ToLower(record)
+		case "upper":
+			record = strings.ToUpper(record)
+		case "trim":
+			record = strings.TrimSpace(record)
+		case "match":
+			if !op.re.MatchString(record) {
+				return "", false
+			}
+		case "skip":
+			if op.re.MatchString(record) {
+				return "", false
+			}
+		}
+	}
+	return record, true
+}
+
+func scriptMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	ms, err := parseMapScript(ctx.Param("map", ""))
+	if err != nil {
+		return nil, err
+	}
+	// reduce is only run on the reducers, checked here to fail early
+	if aggregator := ctx.Param("reduce", ""); !scriptAggregators[aggregator] {
+		return nil, fmt.Errorf("script reduce must be one of sum, count, min, max, distinct, concat: %q", aggregator)
+	}
+
+	kvPairs := &KvPairs{}
+	emit := func(record string, line int) {
+		record, ok := ms.apply(record)
+		if !ok {
+			return
+		}
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{
+			Key:   expandTemplate(ms.key, record, ms.sep, ctx.FileName, line),
+			Value: expandTemplate(ms.value, record, ms.sep, ctx.FileName, line),
+		})
+	}
+	for i, line := range strings.Split(value, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if ms.source == "lines" {
+			emit(line, i+1)
+			continue
+		}
+		for _, word := range splitWords(line) {
+			emit(word, i+1)
+		}
+	}
+
+	return kvPairs, nil
+}
+
+// keys without any numeric value have no sum, min or max, they
+// are left out of the output
+func scriptReduceAll(groups Groups, ctx *TaskContext, emit func(kv *KeyValue) error) error {
+	for groups.Next() {
+		out, ok := scriptReduce(groups.Key(), groupValues(groups), ctx)
+		if !ok {
+			log.Printf("No numeric values for key %s\n", groups.Key())
+			continue
+		}
+		if err := emit(&KeyValue{Key: groups.Key(), Value: out}); err != nil {
+			return err
+		}
+	}
+	return groups.Err()
+}
+
+// aggregated value of the key, false when the numeric aggregators
+// got no numeric value
+func scriptReduce(key string, values []string, ctx *TaskContext) (string, bool) {
+	aggregator := ctx.Param("reduce", "")
+	switch aggregator {
+	case "count":
+		return strconv.Itoa(len(values)), true
+	case "concat":
+		return strings.Join(values, ","), true
+	case "distinct":
+		sort.Strings(values)
+		distinct := []string{}
+		for i, value := range values {
+			if i == 0 || values[i-1] != value {
+				distinct = append(distinct, value)
+			}
+		}
+		return strings.Join(distinct, ","), true
+	}
+
+	// numeric aggregators
+	var result float64
+	seen := false
+	for _, value := range values {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			log.Printf("Error converting value: %s\n", value)
+			continue
+		}
+		switch {
+		case aggregator == "sum":
+			result += number
+		case !seen, aggregator == "min" && number < result, aggregator == "max" && number > result:
+			result = number
+		}
+		seen = true
+	}
+	return strconv.FormatFloat(result, 'g', -1, 64), seen
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+// keys with no numeric value are left out of sum, min and max outputs
+func TestScriptReduceWithoutNumbers(t *testing.T) {
+	kvs := []*KeyValue{
+		{Key: "a", Value: "n/a"},
+		{Key: "a", Value: ""},
+		{Key: "b", Value: "x"},
+		{Key: "b", Value: "4"},
+		{Key: "b", Value: "-2"},
+	}
+	tests := map[string]string{
+		"sum":   "b: 2",
+		"min":   "b: -2",
+		"max":   "b: 4",
+		"count": "a: 2|b: 3",
+	}
+	for aggregator, want := range tests {
+		ctx := newTaskContext(map[string]string{"reduce": aggregator})
+		groups := newGroupIterator(&sliceSource{kvs: kvs})
+		out := []string{}
+		err := scriptReduceAll(groups, ctx, func(kv *KeyValue) error {
+			out = append(out, kv.Key+": "+kv.Value)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", aggregator, err)
+		}
+		if got := strings.Join(out, "|"); got != want {
+			t.Errorf("%s: output %q, want %q", aggregator, got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+)
+
+// distributed sort
+// sorts the lines of all the input files, the job is range partitioned
+// so reducer outputs concatenated in port order are totally ordered
+// emits "line: occurrences"
+
+func sortMap(_key, value string, _ *TaskContext) (*KvPairs, error) {
+	kvPairs := &KvPairs{}
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: line, Value: "1"})
+	}
+
+	return kvPairs, nil
+}
+
+func sortReduce(_key string, values []string, _ *TaskContext) string {
+	return strconv.Itoa(len(values))
+}
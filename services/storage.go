@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// storage of the job input and output files, referenced by URI:
+// paths without a scheme (or file://) are on the local file system
+// and s3://bucket/key objects are in an S3 compatible object store
+// (AWS S3, MinIO...) configured in config.json
+type Storage interface {
+	// opens the file for reading
+	Open(path string) (io.ReadCloser, error)
+	// creates the file, its content is stored when the writer is closed
+	Create(path string) (io.WriteCloser, error)
+	// paths of the files under the directory relative to it,
+	// recursively and in lexical order. None when it is not a directory
+	List(dir string) ([]string, error)
+}
+
+// storage section of config.json, the credentials of the object
+// store are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+// (and AWS_SESSION_TOKEN for temporary credentials)
+type StorageConfig struct {
+	S3 struct {
+		// url of the object store, objects are addressed path style
+		// (endpoint/bucket/key). AWS S3 of the region by default
+		Endpoint string `json:"endpoint"`
+		// us-east-1 by default
+		Region string `json:"region"`
+	} `json:"s3"`
+}
+
+// error opening a directory as a file
+var errDirectory = errors.New("is a directory")
+
+// set from config.json by every process
+var StorageSettings StorageConfig
+
+var s3Once sync.Once
+var s3Default *s3Storage
+var s3Err error
+
+// storage and path of a file URI
+func resolveURI(uri string) (Storage, string, error) {
+	scheme, path, ok := strings.Cut(uri, "://")
+	if !ok {
+		return localStorage{}, uri, nil
+	}
+	switch scheme {
+	case "file":
+		return localStorage{}, path, nil
+	case "s3":
+		s3Once.Do(func() {
+			s3Default, s3Err = newS3Storage(StorageSettings)
+		})
+		return s3Default, path, s3Err
+	}
+	return nil, "", fmt.Errorf("unsupported storage %q in %s", scheme, uri)
+}
+
+// uri of the file name under the directory uri
+func joinURI(dir, name string) string {
+	return strings.TrimSuffix(dir, "/") + "/" + name
+}
+
+func openURI(uri string) (io.ReadCloser, error) {
+	storage, path, err := resolveURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(path)
+}
+
+func createURI(uri string) (io.WriteCloser, error) {
+	storage, path, err := resolveURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Create(path)
+}
+
+// names of the files under the directory uri, relative to it
+func listURI(uri string) ([]string, error) {
+	storage, path, err := resolveURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return storage.List(path)
+}
+
+func readURI(uri string) ([]byte, error) {
+	reader, err := openURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func writeURI(uri string, data []byte) error {
+	writer, err := createURI(uri)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copies the local file to the uri
+func copyToURI(filePath, uri string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer, err := createURI(uri)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// local file system, directories are created on demand
+type localStorage struct{}
+
+func (localStorage) Open(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err == nil && info.IsDir() {
+		err = fmt.Errorf("%s: %w", path, errDirectory)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func (localStorage) Create(path string) (io.WriteCloser, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+func (localStorage) List(dir string) ([]string, error) {
+	names := []string{}
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) || err == nil && !info.IsDir() {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+		return nil
+	})
+	return names, err
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// streaming jobs run user executables as map and reduce functions
+// parameters:
+//   mapper: command every input file is piped to over stdin, with
+//   MAP_INPUT_FILE and MAP_INPUT_DATASET set
+//   reducer: command the sorted "key\tvalue" lines of the reducer
+//   are piped to, every value is passed unchanged when empty
+// commands are run with sh -c and write "key\tvalue" lines to stdout,
+// a line without a tab is a key with an empty value
+// ex: mapper="awk '{for (i = 1; i <= NF; i++) print $i \"\t1\"}'"
+
+// runs the command with input on stdin and parses its output
+// lines as they are written
+func runStreamingCommand(command string, input io.Reader, env []string, emit func(kv *KeyValue) error) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = input
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("streaming command %q failed: %v", command, err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var emitErr error
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) == 0 || emitErr != nil {
+			continue
+		}
+		kv := strings.SplitN(line, "\t", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+		emitErr = emit(&KeyValue{Key: kv[0], Value: kv[1]})
+	}
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		// unblocks the command writing to stdout
+		io.Copy(io.Discard, stdout)
+	}
+
+	err = cmd.Wait()
+	if stderr.Len() > 0 {
+		log.Printf("Streaming command stderr: %s\n", stderr.String())
+	}
+	if err != nil {
+		return fmt.Errorf("streaming command %q failed: %v", command, err)
+	}
+	if scanErr != nil {
+		return scanErr
+	}
+	return emitErr
+}
+
+func streamingMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	command := ctx.Param("mapper", "")
+	if len(command) == 0 {
+		return nil, fmt.Errorf("streaming job needs a mapper parameter")
+	}
+	env := []string{"MAP_INPUT_FILE=" + ctx.FileName, "MAP_INPUT_DATASET=" + ctx.Dataset}
+	kvPairs := &KvPairs{}
+	err := runStreamingCommand(command, strings.NewReader(value), env, func(kv *KeyValue) error {
+		kvPairs.Data = append(kvPairs.Data, kv)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kvPairs, nil
+}
+
+// the reducer command gets all the values of the reducer grouped
+// by key and in key order, like the input of a reduce task. Groups
+// are piped to the command as they are merged
+func streamingReduceAll(groups Groups, ctx *TaskContext, emit func(kv *KeyValue) error) error {
+	command := ctx.Param("reducer", "")
+	if len(command) == 0 {
+		return identityReduceAll(groups, ctx, emit)
+	}
+
+	reader, writer := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		w := bufio.NewWriter(writer)
+		for groups.Next() {
+			for _, value := range groupValues(groups) {
+				if _, err := w.WriteString(groups.Key() + "\t" + value + "\n"); err != nil {
+					writer.CloseWithError(err)
+					written <- err
+					return
+				}
+			}
+		}
+		err := groups.Err()
+		if err == nil {
+			err = w.Flush()
+		}
+		writer.CloseWithError(err)
+		written <- groups.Err()
+	}()
+
+	err := runStreamingCommand(command, reader, nil, emit)
+	// the command may exit before reading all of its input
+	reader.Close()
+	groupsErr := <-written
+	if err != nil {
+		return err
+	}
+	if groupsErr != nil && groupsErr != io.ErrClosedPipe {
+		return groupsErr
+	}
+	return nil
+}
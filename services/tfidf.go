@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tf-idf of every (term, document) pair, run as two stages
+// tfidf_tf: counts the term frequencies, "term\tdocument: tf"
+// tfidf_idf: groups the term frequencies by term, the number of
+// documents the term appears in gives the document frequency
+// and the number of input files gives the total document count
+// emits "term: document=score,document=score" with score = tf * log(N/df)
+
+// the input file is the document
+func tfMap(_key, value string, ctx *TaskContext) (*KvPairs, error) {
+	kvPairs := &KvPairs{}
+	for _, word := range splitWords(value) {
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: word + "\t" + ctx.FileName, Typed: IntValue(1)})
+	}
+
+	return kvPairs, nil
+}
+
+// value is a reducer output of the tfidf_tf stage
+func idfMap(_key, value string, _ *TaskContext) (*KvPairs, error) {
+	kvPairs := &KvPairs{}
+	for _, kv := range parseOutputLines(value) {
+		termDoc := strings.SplitN(kv.Key, "\t", 2)
+		if len(termDoc) != 2 {
+			return nil, fmt.Errorf("invalid term frequency record: %s", kv.Key)
+		}
+		kvPairs.Data = append(kvPairs.Data, &KeyValue{Key: termDoc[0], Value: termDoc[1] + "\t" + kv.Value})
+	}
+
+	return kvPairs, nil
+}
+
+// values are "document\ttf"
+func idfReduce(key string, values []string, ctx *TaskContext) string {
+	nDocs, err := ctx.IntParam("nInputFiles", 0)
+	if err != nil || nDocs == 0 {
+		log.Printf("Error reading the number of documents: %v\n", err)
+		return ""
+	}
+	idf := math.Log(float64(nDocs) / float64(len(values)))
+
+	scores := []string{}
+	for _, value := range values {
+		docTf := strings.SplitN(value, "\t", 2)
+		if len(docTf) != 2 {
+			log.Printf("Error parsing term frequency: %s\n", value)
+			continue
+		}
+		tf, err := strconv.Atoi(docTf[1])
+		if err != nil {
+			log.Printf("Error converting value: %s\n", docTf[1])
+			continue
+		}
+		scores = append(scores, fmt.Sprintf("%s=%.6f", docTf[0], float64(tf)*idf))
+	}
+	sort.Strings(scores)
+
+	return strings.Join(scores, ",")
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"container/heap"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// top k words
+// parameters: k (default 10)
+// counts words like wc, every reducer keeps its local top k
+// and the master merges them into a single ranked topk.txt
+
+const defaultTopK = 10
+
+type wordCount struct {
+	word  string
+	count int
+}
+
+// ranks higher counts first, ties by word
+func rankedBefore(a, b wordCount) bool {
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	return a.word < b.word
+}
+
+// min heap on the rank, the root is the lowest ranked word kept so far
+type wordCountHeap []wordCount
+
+func (h wordCountHeap) Len() int           { return len(h) }
+func (h wordCountHeap) Less(i, j int) bool { return rankedBefore(h[j], h[i]) }
+func (h wordCountHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *wordCountHeap) Push(x any)        { *h = append(*h, x.(wordCount)) }
+func (h *wordCountHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// keeps the k highest ranked words of the key value pairs
+// values are the counts, returned in rank order
+func topK(kvs []*KeyValue, k int) []*KeyValue {
+	h := &wordCountHeap{}
+	for _, kv := range kvs {
+		count := int(kv.Typed.GetInt())
+		if kv.Typed == nil {
+			// parsed from a reducer output file
+			var err error
+			count, err = strconv.Atoi(kv.Value)
+			if err != nil {
+				log.Printf("Error converting value: %s\n", kv.Value)
+				continue
+			}
+		}
+		pushTopK(h, wordCount{word: kv.Key, count: count}, k)
+	}
+	return rankedTopK(h)
+}
+
+// adds the word to the heap when it is among the k highest ranked
+func pushTopK(h *wordCountHeap, wc wordCount, k int) {
+	if h.Len() < k {
+		heap.Push(h, wc)
+	} else if rankedBefore(wc, (*h)[0]) {
+		(*h)[0] = wc
+		heap.Fix(h, 0)
+	}
+}
+
+// words of the heap in rank order, values are the counts
+func rankedTopK(h *wordCountHeap) []*KeyValue {
+	ranked := []wordCount(*h)
+	sort.Slice(ranked, func(i, j int) bool { return rankedBefore(ranked[i], ranked[j]) })
+	out := make([]*KeyValue, 0, len(ranked))
+	for _, wc := range ranked {
+		out = append(out, &KeyValue{Key: wc.word, Value: strconv.Itoa(wc.count), Typed: IntValue(int64(wc.count))})
+	}
+	return out
+}
+
+func topkParam(ctx *TaskContext) (int, error) {
+	k, err := ctx.IntParam("k", defaultTopK)
+	if err != nil {
+		return 0, err
+	}
+	if k <= 0 {
+		return 0, fmt.Errorf("k must be positive: %d", k)
+	}
+	return k, nil
+}
+
+// counts the words like wcReduce and keeps the local top k of the
+// reducer, only k words are held in memory
+func topkReduceAll(groups Groups, ctx *TaskContext, emit func(kv *KeyValue) error) error {
+	k, err := topkParam(ctx)
+	if err != nil {
+		return err
+	}
+	h := &wordCountHeap{}
+	for groups.Next() {
+		values := make([]*Value, 0, len(groups.Values()))
+		for _, kv := range groups.Values() {
+			values = append(values, valueOf(kv))
+		}
+		count, err := wcReduce(groups.Key(), values, ctx)
+		if err != nil {
+			return fmt.Errorf("reducing key %s: %v", groups.Key(), err)
+		}
+		pushTopK(h, wordCount{word: groups.Key(), count: int(count.GetInt())}, k)
+	}
+	if err := groups.Err(); err != nil {
+		return err
+	}
+	for _, kv := range rankedTopK(h) {
+		if err := emit(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// global top k from the local top k of every reducer, a word is
+// counted by one reducer only so the local counts are final
+func topkMerge(outputs []*FileOutput, ctx *TaskContext) (*FileOutput, error) {
+	k, err := topkParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kvs := []*KeyValue{}
+	for _, output := range outputs {
+		if output == nil {
+			return nil, fmt.Errorf("missing reducer output")
+		}
+		kvs = append(kvs, parseOutputLines(string(output.Data))...)
+	}
+
+	var sb strings.Builder
+	for _, kv := range topK(kvs, k) {
+		sb.WriteString(fmt.Sprintf("%s: %s\n", kv.Key, kv.Value))
+	}
+	return &FileOutput{Name: "topk.txt", Data: []byte(sb.String())}, nil
+}
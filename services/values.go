@@ -0,0 +1,128 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// typed values of the intermediate key value pairs, numeric jobs
+// emit and reduce typed values instead of formatting and parsing strings
+
+func StrValue(s string) *Value {
+	return &Value{Kind: &Value_Str{Str: s}}
+}
+
+func IntValue(i int64) *Value {
+	return &Value{Kind: &Value_Int{Int: i}}
+}
+
+func RealValue(f float64) *Value {
+	return &Value{Kind: &Value_Real{Real: f}}
+}
+
+func BytesValue(b []byte) *Value {
+	return &Value{Kind: &Value_Raw{Raw: b}}
+}
+
+func ListValue(values ...*Value) *Value {
+	return &Value{Kind: &Value_List{List: &Values{Values: values}}}
+}
+
+// AnyValue wraps a message, nested messages are decoded by the
+// reduce function with anypb.UnmarshalNew or Any.UnmarshalTo
+func AnyValue(m proto.Message) (*Value, error) {
+	a, err := anypb.New(m)
+	if err != nil {
+		return nil, err
+	}
+	return &Value{Kind: &Value_Any{Any: a}}, nil
+}
+
+// typed value of the pair, the string value when it is not typed
+func valueOf(kv *KeyValue) *Value {
+	if kv.Typed != nil {
+		return kv.Typed
+	}
+	return StrValue(kv.Value)
+}
+
+// string value of the pair, typed values are formatted
+func textOf(kv *KeyValue) string {
+	if kv.Typed != nil {
+		return formatValue(kv.Typed)
+	}
+	return kv.Value
+}
+
+// text of a value in the output files
+func formatValue(v *Value) string {
+	switch kind := v.GetKind().(type) {
+	case *Value_Str:
+		return kind.Str
+	case *Value_Int:
+		return strconv.FormatInt(kind.Int, 10)
+	case *Value_Real:
+		return strconv.FormatFloat(kind.Real, 'g', -1, 64)
+	case *Value_Raw:
+		return base64.StdEncoding.EncodeToString(kind.Raw)
+	case *Value_List:
+		out := make([]string, 0, len(kind.List.GetValues()))
+		for _, value := range kind.List.GetValues() {
+			out = append(out, formatValue(value))
+		}
+		return "[" + strings.Join(out, ",") + "]"
+	case *Value_Any:
+		out, err := protojson.Marshal(kind.Any)
+		if err != nil {
+			return kind.Any.GetTypeUrl()
+		}
+		return string(out)
+	}
+	return ""
+}
+
+// sums int values, the sum is a real value when any value is real
+func sumValues(values []*Value) (*Value, error) {
+	var intSum int64
+	var realSum float64
+	isReal := false
+	for _, value := range values {
+		switch kind := value.GetKind().(type) {
+		case *Value_Int:
+			intSum += kind.Int
+		case *Value_Real:
+			realSum += kind.Real
+			isReal = true
+		default:
+			return nil, fmt.Errorf("cannot sum %s value", valueKind(value))
+		}
+	}
+	if isReal {
+		return RealValue(realSum + float64(intSum)), nil
+	}
+	return IntValue(intSum), nil
+}
+
+func valueKind(v *Value) string {
+	switch v.GetKind().(type) {
+	case *Value_Str:
+		return "string"
+	case *Value_Int:
+		return "int"
+	case *Value_Real:
+		return "real"
+	case *Value_Raw:
+		return "bytes"
+	case *Value_List:
+		return "list"
+	case *Value_Any:
+		return "any"
+	}
+	return "empty"
+}